@@ -63,13 +63,66 @@ func init() {
 func displayConfig(config *configs.Config) {
 	fmt.Println("Saved Configuration:")
 	fmt.Printf("From Email: %s\n", config.SMTP.FromEmail)
+	fmt.Printf("From Name: %s\n", config.SMTP.FromName)
 	fmt.Printf("Credential: %s\n", config.SMTP.Credentials)
 	fmt.Printf("Port: %d\n", config.SMTP.Port)
 	fmt.Printf("Host: %s\n", config.SMTP.Host)
+	fmt.Printf("Security: %s\n", config.SMTP.Security)
+	fmt.Printf("Auth: %s\n", config.SMTP.Auth)
+	fmt.Printf("Dial Timeout: %ds\n", config.SMTP.DialTimeout)
+	fmt.Printf("IO Timeout: %ds\n", config.SMTP.IOTimeout)
+	fmt.Printf("Retries: %d\n", config.SMTP.Retries)
+	fmt.Printf("Max Retry Delay: %ds\n", config.SMTP.RetryDelay)
+	fmt.Printf("Message-ID Domain: %s\n", config.SMTP.MessageIDDomain)
+	fmt.Printf("HELO Name: %s\n", config.SMTP.HeloName)
+	fmt.Printf("Local Address: %s\n", config.SMTP.LocalAddress)
+	fmt.Printf("IP Family: %s\n", config.SMTP.IPFamily)
+	fmt.Printf("TLS CA File: %s\n", config.SMTP.TLS.CAFile)
+	fmt.Printf("TLS Client Certificate: %s\n", config.SMTP.TLS.CertFile)
+	fmt.Printf("TLS Client Key: %s\n", config.SMTP.TLS.KeyFile)
+	fmt.Printf("TLS Server Name: %s\n", config.SMTP.TLS.ServerName)
+	fmt.Printf("TLS Minimum Version: %s\n", config.SMTP.TLS.MinVersion)
+	fmt.Printf("TLS Pinned Fingerprints: %s\n", strings.Join(config.SMTP.TLS.Fingerprints, ", "))
+	fmt.Printf("TLS Insecure Skip Verify: %t\n", config.SMTP.TLS.InsecureSkipVerify)
+	fmt.Printf("Transport: %s\n", config.Transport.Type)
+	fmt.Printf("Sendmail Path: %s\n", config.Transport.SendmailPath)
+	fmt.Printf("Transport Directory: %s\n", config.Transport.Dir)
+	fmt.Printf("DKIM Domain: %s\n", config.DKIM.Domain)
+	fmt.Printf("DKIM Selector: %s\n", config.DKIM.Selector)
+	fmt.Printf("DKIM Private Key: %s\n", config.DKIM.PrivateKey)
+	fmt.Printf("DKIM Signed Headers: %s\n", strings.Join(config.DKIM.Headers, ", "))
+	fmt.Printf("Protection: %s\n", config.Protection)
+	fmt.Printf("PGP Keyring: %s\n", config.PGP.Keyring)
+	fmt.Printf("PGP Signing Key: %s\n", config.PGP.SigningKey)
+	fmt.Printf("PGP Passphrase: %s\n", maskSecret(config.PGP.Passphrase))
+	fmt.Printf("S/MIME Certificate: %s\n", config.SMIME.Certificate)
+	fmt.Printf("S/MIME Private Key: %s\n", config.SMIME.PrivateKey)
+	fmt.Printf("S/MIME Recipient Certificates: %s\n", config.SMIME.Certificates)
+	fmt.Printf("SendGrid API Key: %s\n", maskSecret(config.SendGrid.APIKey))
+	fmt.Printf("SendGrid Endpoint: %s\n", config.SendGrid.Endpoint)
+	fmt.Printf("Mailgun API Key: %s\n", maskSecret(config.Mailgun.APIKey))
+	fmt.Printf("Mailgun Domain: %s\n", config.Mailgun.Domain)
+	fmt.Printf("Mailgun Endpoint: %s\n", config.Mailgun.Endpoint)
+	fmt.Printf("SES Region: %s\n", config.SES.Region)
+	fmt.Printf("SES Access Key ID: %s\n", config.SES.AccessKeyID)
+	fmt.Printf("SES Secret Access Key: %s\n", maskSecret(config.SES.SecretAccessKey))
+	fmt.Printf("SES Session Token: %s\n", maskSecret(config.SES.SessionToken))
+	fmt.Printf("SES Endpoint: %s\n", config.SES.Endpoint)
+	fmt.Printf("Postmark Server Token: %s\n", maskSecret(config.Postmark.ServerToken))
+	fmt.Printf("Postmark Message Stream: %s\n", config.Postmark.MessageStream)
+	fmt.Printf("Postmark Endpoint: %s\n", config.Postmark.Endpoint)
 	fmt.Printf("Default Recipient: %s\n", config.DefaultRecipient)
 	fmt.Printf("Setup Completed: %v\n", config.SetupCompleted)
 }
 
+// Function to show whether a secret is configured without printing it
+func maskSecret(secret string) string {
+	if secret == "" {
+		return "(not set)"
+	}
+	return "(set)"
+}
+
 // Function to initiate first-time setup
 func initiateSetup(config *configs.Config, configPath string) {
 	reader := bufio.NewReader(os.Stdin)
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/lordofthemind/dhanu/internals/services"
+	"github.com/lordofthemind/dhanu/pkgs/configs"
+	"github.com/spf13/cobra"
+)
+
+// dkimCmd represents the dkim command
+var dkimCmd = &cobra.Command{
+	Use:   "dkim",
+	Short: "Manage DKIM signing keys",
+	Long: `Manage the keys used to DKIM sign outgoing email, for example:
+
+dhanu dkim keygen --selector mail --domain example.com --out ~/.config/dhanu/dkim.pem`,
+}
+
+// dkimKeygenCmd represents the dkim keygen command
+var dkimKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a DKIM key pair and print the DNS record to publish",
+	Run: func(cmd *cobra.Command, args []string) {
+		generateDKIMKey(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dkimCmd)
+	dkimCmd.AddCommand(dkimKeygenCmd)
+
+	dkimKeygenCmd.Flags().String("type", services.DKIMKeyRSA, "Key type: rsa or ed25519")
+	dkimKeygenCmd.Flags().Int("bits", 2048, "RSA key size in bits")
+	dkimKeygenCmd.Flags().String("selector", "", "DKIM selector (defaults to the configured selector)")
+	dkimKeygenCmd.Flags().String("domain", "", "Signing domain (defaults to the configured DKIM domain or the from_email domain)")
+	dkimKeygenCmd.Flags().StringP("out", "o", "dkim.pem", "Path of the private key; the public key is written next to it with a .pub suffix")
+}
+
+func generateDKIMKey(cmd *cobra.Command) {
+	config, _, err := configs.LoadConfig()
+	if err != nil {
+		log.Println("Error loading configuration:", err)
+		return
+	}
+
+	keyType, _ := cmd.Flags().GetString("type")
+	bits, _ := cmd.Flags().GetInt("bits")
+	selector, _ := cmd.Flags().GetString("selector")
+	domain, _ := cmd.Flags().GetString("domain")
+	out, _ := cmd.Flags().GetString("out")
+
+	if selector == "" {
+		selector = config.DKIM.Selector
+	}
+	if domain == "" {
+		domain = dkimDomain(&config)
+	}
+	if selector == "" || domain == "" {
+		log.Println("Error: --selector and --domain are required when they are not configured.")
+		return
+	}
+
+	// Refuse to overwrite an existing key, which would invalidate the published record
+	if _, err := os.Stat(out); err == nil {
+		log.Printf("Error: %s already exists.\n", out)
+		return
+	}
+
+	key, err := services.GenerateDKIMKey(keyType, bits)
+	if err != nil {
+		log.Printf("Error generating DKIM key: %v\n", err)
+		return
+	}
+	privatePEM, publicPEM, err := services.MarshalDKIMKey(key)
+	if err != nil {
+		log.Printf("Error encoding DKIM key: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(out, privatePEM, 0o600); err != nil {
+		log.Printf("Error writing private key: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(out+".pub", publicPEM, 0o644); err != nil {
+		log.Printf("Error writing public key: %v\n", err)
+		return
+	}
+	record, err := services.DKIMRecord(key)
+	if err != nil {
+		log.Printf("Error creating DNS record: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Private key written to %s\n", out)
+	fmt.Printf("Public key written to %s.pub\n\n", out)
+	fmt.Println("Publish this DNS TXT record:")
+	fmt.Printf("%s._domainkey.%s. IN TXT %s\n\n", selector, domain, quoteTXT(record))
+	fmt.Println("Then add to your configuration:")
+	fmt.Printf("dkim:\n  domain: %s\n  selector: %s\n  private_key: %s\n", domain, selector, out)
+}
+
+// newDKIMSigner creates the DKIM signer described by the configuration.
+func newDKIMSigner(config *configs.Config) (*services.DKIMSigner, error) {
+	key, err := services.LoadDKIMKey(config.DKIM.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewDKIMSigner(dkimDomain(config), config.DKIM.Selector, key, config.DKIM.Headers)
+}
+
+// dkimDomain returns the configured DKIM domain, falling back to the domain of from_email.
+func dkimDomain(config *configs.Config) string {
+	if config.DKIM.Domain != "" {
+		return config.DKIM.Domain
+	}
+	if at := strings.LastIndex(config.SMTP.FromEmail, "@"); at >= 0 {
+		return config.SMTP.FromEmail[at+1:]
+	}
+	return ""
+}
+
+// quoteTXT splits a TXT record value into quoted strings of at most 255
+// characters, the limit of a single DNS character-string.
+func quoteTXT(value string) string {
+	var parts []string
+	for len(value) > 255 {
+		parts = append(parts, `"`+value[:255]+`"`)
+		value = value[255:]
+	}
+	parts = append(parts, `"`+value+`"`)
+	return "( " + strings.Join(parts, " ") + " )"
+}
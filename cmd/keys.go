@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lordofthemind/dhanu/internals/services"
+	"github.com/lordofthemind/dhanu/pkgs/configs"
+	"github.com/spf13/cobra"
+)
+
+// keysCmd represents the keys command
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the PGP keyring used to encrypt email",
+	Long: `Manage the recipients' PGP public keys used by send --encrypt, for example:
+
+dhanu keys import alice.asc
+dhanu keys list
+dhanu keys remove alice@example.com`,
+}
+
+// keysImportCmd represents the keys import command
+var keysImportCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Import public keys from armored or binary key files",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyring, ok := openKeyring()
+		if !ok {
+			return
+		}
+		for _, path := range args {
+			file, err := os.Open(path)
+			if err != nil {
+				log.Printf("Error opening key file: %v\n", err)
+				return
+			}
+			imported, err := keyring.Import(file)
+			file.Close()
+			if err != nil {
+				log.Printf("Error importing %s: %v\n", path, err)
+				return
+			}
+			for _, info := range imported {
+				fmt.Printf("Imported %s %s\n", info.Fingerprint, strings.Join(info.Identities, ", "))
+			}
+		}
+	},
+}
+
+// keysListCmd represents the keys list command
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys in the keyring",
+	Run: func(cmd *cobra.Command, args []string) {
+		keyring, ok := openKeyring()
+		if !ok {
+			return
+		}
+		infos, err := keyring.List()
+		if err != nil {
+			log.Printf("Error reading keyring: %v\n", err)
+			return
+		}
+		if len(infos) == 0 {
+			fmt.Println("The keyring is empty.")
+			return
+		}
+		for _, info := range infos {
+			usage := "encrypt"
+			if !info.CanEncrypt {
+				usage = "no encryption key"
+			}
+			fmt.Printf("%s  created %s  (%s)\n", info.Fingerprint, info.Created.Format("2006-01-02"), usage)
+			for _, identity := range info.Identities {
+				fmt.Printf("    %s\n", identity)
+			}
+		}
+	},
+}
+
+// keysRemoveCmd represents the keys remove command
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove <fingerprint|key-id|email>",
+	Short: "Remove keys from the keyring",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyring, ok := openKeyring()
+		if !ok {
+			return
+		}
+		removed, err := keyring.Remove(args[0])
+		if err != nil {
+			log.Printf("Error removing key: %v\n", err)
+			return
+		}
+		for _, info := range removed {
+			fmt.Printf("Removed %s %s\n", info.Fingerprint, strings.Join(info.Identities, ", "))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysImportCmd, keysListCmd, keysRemoveCmd)
+}
+
+// openKeyring opens the configured keyring, logging any configuration error.
+func openKeyring() (*services.Keyring, bool) {
+	config, configPath, err := configs.LoadConfig()
+	if err != nil {
+		log.Println("Error loading configuration:", err)
+		return nil, false
+	}
+	return services.NewKeyring(keyringDir(&config, configPath)), true
+}
+
+// keyringDir returns the configured keyring directory, defaulting to
+// "keyring" next to the configuration file.
+func keyringDir(config *configs.Config, configPath string) string {
+	if config.PGP.Keyring != "" {
+		return config.PGP.Keyring
+	}
+	return filepath.Join(filepath.Dir(configPath), "keyring")
+}
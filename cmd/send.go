@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/lordofthemind/dhanu/internals/services"
 	"github.com/lordofthemind/dhanu/internals/utils"
 	"github.com/lordofthemind/dhanu/pkgs/configs"
@@ -19,8 +24,11 @@ var sendCmd = &cobra.Command{
 	Short: "Send an email with optional attachments",
 	Long: `Send an email to a recipient with a subject, body. 
 You can also specify attachments or folders to zip and attach.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		sendEmail(cmd)
+	// Errors are logged by sendEmail; the returned error only sets the exit status.
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendEmail(cmd)
 	},
 }
 
@@ -28,68 +36,131 @@ func init() {
 	rootCmd.AddCommand(sendCmd)
 
 	// Define flags for sending email
-	sendCmd.Flags().StringP("to", "t", "", "Recipient email address")
+	sendCmd.Flags().StringSliceP("to", "t", nil, "Recipient email addresses (comma-separated or repeated)")
+	sendCmd.Flags().StringSlice("cc", nil, "CC recipient email addresses (comma-separated or repeated)")
+	sendCmd.Flags().StringSlice("bcc", nil, "BCC recipient email addresses, kept out of the headers (comma-separated or repeated)")
+	sendCmd.Flags().StringSlice("reply-to", nil, "Reply-To email addresses (comma-separated or repeated)")
 	sendCmd.Flags().StringP("subject", "s", "", "Email subject")
 	sendCmd.Flags().StringP("body", "b", "", "Email body text")
-	sendCmd.Flags().StringP("body-file", "f", "", "Path to text file for email body")
+	sendCmd.Flags().StringP("body-file", "f", "", "Path to text file for email body (.html/.htm files are sent as HTML)")
+	sendCmd.Flags().Bool("html", false, "Treat the email body as HTML and include a plain-text alternative")
+	sendCmd.Flags().String("html-file", "", "Path to HTML file for email body (--body/--body-file then become the plain-text alternative)")
 	sendCmd.Flags().StringP("attachments", "a", "", "Comma-separated list of file paths or folders to attach to the email")
+	sendCmd.Flags().Bool("sign", false, "Sign the email with your PGP key or S/MIME certificate")
+	sendCmd.Flags().Bool("encrypt", false, "Encrypt the email to every recipient's PGP key or S/MIME certificate")
+	sendCmd.Flags().String("protection", "", "How --sign and --encrypt protect the email: pgp or smime (defaults to the configured value)")
+	sendCmd.Flags().String("transport", "", "Deliver with smtp, sendmail, file, maildir, stdout, sendgrid, mailgun, ses or postmark (defaults to the configured transport)")
+	sendCmd.Flags().Bool("dry-run", false, "Build the email and print its headers and MIME structure without sending it")
+	sendCmd.Flags().String("save-eml", "", "Write the exact message that is sent to this .eml file")
+	sendCmd.Flags().Duration("timeout", 0, "Abort sending if it takes longer than this overall (e.g. 30s, 2m; 0 for no limit)")
+	sendCmd.Flags().StringArray("header", nil, `Custom header as "Key: Value" (repeatable)`)
+	sendCmd.Flags().String("helo-name", "", "Name to introduce this host with in EHLO (defaults to the configured value or the machine's FQDN)")
+	sendCmd.Flags().String("local-address", "", "Local IP address or network interface to connect from (defaults to the configured value)")
+	sendCmd.Flags().String("ip-family", "", "Connect over auto, ipv4 or ipv6 (defaults to the configured value)")
+	sendCmd.Flags().StringSlice("dsn", nil, "Request delivery status notifications: any of success, failure, delay or never, plus full or hdrs")
+	sendCmd.Flags().Int("retries", -1, "Additional attempts after a transient (4xx) failure (defaults to the configured value)")
 }
 
-func sendEmail(cmd *cobra.Command) {
+// sendEmail builds and sends the email described by the flags, logging any error.
+// It returns an error only when sending timed out, so that the command exits non-zero.
+func sendEmail(cmd *cobra.Command) error {
 	// Check if any flags were provided
 	if cmd.Flags().NFlag() == 0 {
 		_ = cmd.Help()
-		return
+		return nil
 	}
 
 	// Load configuration to get default recipient
-	config, _, err := configs.LoadConfig()
+	config, configPath, err := configs.LoadConfig()
 	if err != nil {
 		log.Println("Error loading configuration:", err)
-		return
+		return nil
 	}
 
-	// Get the recipient from the flag or use the default recipient
-	to, _ := cmd.Flags().GetString("to")
-	if to == "" {
-		to = config.DefaultRecipient
+	// Get the recipients from the flags or use the default recipient
+	to, _ := cmd.Flags().GetStringSlice("to")
+	cc, _ := cmd.Flags().GetStringSlice("cc")
+	bcc, _ := cmd.Flags().GetStringSlice("bcc")
+	replyTo, _ := cmd.Flags().GetStringSlice("reply-to")
+	if len(to) == 0 && config.DefaultRecipient != "" {
+		to = []string{config.DefaultRecipient}
 	}
-	if to == "" {
+	if len(to) == 0 {
 		log.Println("Error: No recipient specified and no default recipient found.")
-		return
+		return nil
 	}
 
-	// Validate the recipient email
-	if !utils.IsValidEmail(to) {
-		log.Println("Error: Invalid recipient email address.")
-		return
+	// Validate every recipient email
+	for _, list := range [][]string{to, cc, bcc, replyTo} {
+		for _, address := range list {
+			if !utils.IsValidEmail(address) {
+				log.Printf("Error: Invalid recipient email address: %s\n", address)
+				return nil
+			}
+		}
 	}
 
 	// Get the subject from the flag or use the current Unix timestamp as the subject
 	subject, _ := cmd.Flags().GetString("subject")
 	if subject == "" {
 		subject = fmt.Sprintf("Email sent at %s", time.Now().Format(time.RFC1123))
-	} else if len(subject) > 78 {
-		log.Println("Error: Subject exceeds 78 character limit.")
-		return
 	}
 
 	// Get the body from the flag or read the body from the file
 	body, _ := cmd.Flags().GetString("body")
 	bodyFile, _ := cmd.Flags().GetString("body-file")
+	htmlFile, _ := cmd.Flags().GetString("html-file")
+	isHTML, _ := cmd.Flags().GetBool("html")
 	if body == "" && bodyFile != "" {
 		bodyBytes, err := os.ReadFile(bodyFile)
 		if err != nil {
 			log.Printf("Error reading body file: %v\n", err)
-			return
+			return nil
 		}
 		body = string(bodyBytes)
+
+		// Send HTML body files as HTML without requiring --html
+		switch strings.ToLower(filepath.Ext(bodyFile)) {
+		case ".html", ".htm":
+			isHTML = true
+		}
+	}
+
+	// Split the body into its plain-text and HTML renderings. With --html-file,
+	// any --body or --body-file text becomes the plain-text alternative.
+	var textBody, htmlBody string
+	htmlDir := "."
+	if htmlFile != "" {
+		htmlBytes, err := os.ReadFile(htmlFile)
+		if err != nil {
+			log.Printf("Error reading HTML file: %v\n", err)
+			return nil
+		}
+		textBody, htmlBody = body, string(htmlBytes)
+		htmlDir = filepath.Dir(htmlFile)
+	} else if isHTML {
+		htmlBody = body
+		if bodyFile != "" {
+			htmlDir = filepath.Dir(bodyFile)
+		}
+	} else {
+		textBody = body
 	}
 
 	// Check if body is empty
-	if body == "" {
+	if textBody == "" && htmlBody == "" {
 		log.Println("Error: Email body cannot be empty. Please provide text or a file.")
-		return
+		return nil
+	}
+
+	// Embed images referenced by local path in HTML bodies as inline resources
+	var inline []services.InlineResource
+	if htmlBody != "" {
+		htmlBody, inline, err = services.EmbedLocalImages(htmlBody, htmlDir)
+		if err != nil {
+			log.Printf("Error embedding inline images: %v\n", err)
+			return nil
+		}
 	}
 
 	// Get the attachments as a comma-separated string and split into a slice
@@ -103,42 +174,342 @@ func sendEmail(cmd *cobra.Command) {
 	err = utils.HandleAttachments(attachments)
 	if err != nil {
 		log.Fatalf("Error with attachments: %v\n", err)
-		return
+		return nil
+	}
+
+	// Parse the custom headers given as "Key: Value"
+	headerFlags, _ := cmd.Flags().GetStringArray("header")
+	var headers []services.Header
+	for _, raw := range headerFlags {
+		name, value, ok := strings.Cut(raw, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			log.Printf("Error: Invalid header %q, expected \"Key: Value\".\n", raw)
+			return nil
+		}
+		headers = append(headers, services.Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+
+	// Determine how the SMTP connection should be secured
+	security, err := services.ParseSecurityMode(config.SMTP.Security)
+	if err != nil {
+		log.Printf("Error in configuration: %v\n", err)
+		return nil
+	}
+
+	// Determine which SMTP authentication mechanism to use
+	auth, err := services.ParseAuthMechanism(config.SMTP.Auth)
+	if err != nil {
+		log.Printf("Error in configuration: %v\n", err)
+		return nil
+	}
+
+	// Use the configured retry count unless --retries overrides it
+	retries := config.SMTP.Retries
+	if cmd.Flags().Changed("retries") {
+		retries, _ = cmd.Flags().GetInt("retries")
+	}
+	if retries < 0 {
+		log.Println("Error: --retries cannot be negative.")
+		return nil
+	}
+
+	// Use the configured EHLO name, local address and IP family unless the flags override them
+	heloName, _ := cmd.Flags().GetString("helo-name")
+	if heloName == "" {
+		heloName = config.SMTP.HeloName
+	}
+	localAddress, _ := cmd.Flags().GetString("local-address")
+	if localAddress == "" {
+		localAddress = config.SMTP.LocalAddress
+	}
+	ipFamilyName, _ := cmd.Flags().GetString("ip-family")
+	if ipFamilyName == "" {
+		ipFamilyName = config.SMTP.IPFamily
+	}
+	ipFamily, err := services.ParseIPFamily(ipFamilyName)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return nil
+	}
+
+	// Load the CA bundle, client certificate and pins of the smtp.tls section
+	tlsConfig, err := services.NewTLSConfig(services.TLSOptions{
+		CAFile:             config.SMTP.TLS.CAFile,
+		CertFile:           config.SMTP.TLS.CertFile,
+		KeyFile:            config.SMTP.TLS.KeyFile,
+		ServerName:         config.SMTP.TLS.ServerName,
+		MinVersion:         config.SMTP.TLS.MinVersion,
+		Fingerprints:       config.SMTP.TLS.Fingerprints,
+		InsecureSkipVerify: config.SMTP.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		log.Printf("Error in TLS configuration: %v\n", err)
+		return nil
 	}
 
 	// Initialize the Dhanu email service with configuration values
+	opts := []services.DhanuEmailServiceOption{
+		services.WithSecurity(security),
+		services.WithTLSConfig(tlsConfig),
+		services.WithHelloName(heloName),
+		services.WithLocalAddress(localAddress),
+		services.WithIPFamily(ipFamily),
+		services.WithAuth(auth),
+		services.WithFromName(config.SMTP.FromName),
+		services.WithTimeouts(
+			time.Duration(config.SMTP.DialTimeout)*time.Second,
+			time.Duration(config.SMTP.IOTimeout)*time.Second,
+		),
+		services.WithRetry(retries, time.Duration(config.SMTP.RetryDelay)*time.Second),
+		services.WithMessageIDDomain(config.SMTP.MessageIDDomain),
+	}
+
+	// Sign outgoing mail with DKIM when a private key is configured
+	if config.DKIM.PrivateKey != "" {
+		signer, err := newDKIMSigner(&config)
+		if err != nil {
+			log.Printf("Error in DKIM configuration: %v\n", err)
+			return nil
+		}
+		opts = append(opts, services.WithDKIM(signer))
+	}
+
+	// Request delivery status notifications from servers that support DSN
+	if dsnFlags, _ := cmd.Flags().GetStringSlice("dsn"); len(dsnFlags) > 0 {
+		dsn, err := services.ParseDSN(dsnFlags)
+		if err != nil {
+			log.Printf("Error: %v\n", err)
+			return nil
+		}
+		opts = append(opts, services.WithDSN(dsn))
+	}
+
+	// Deliver through another transport than SMTP when configured or requested
+	transportName, _ := cmd.Flags().GetString("transport")
+	if transportName == "" {
+		transportName = config.Transport.Type
+	}
+	transport, err := newTransport(&config, transportName)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return nil
+	}
+	if transport != nil {
+		opts = append(opts, services.WithTransport(transport))
+	}
+
+	// Set up PGP/MIME or S/MIME when the email is to be signed or encrypted
+	sign, _ := cmd.Flags().GetBool("sign")
+	encrypt, _ := cmd.Flags().GetBool("encrypt")
+	if sign || encrypt {
+		protection, _ := cmd.Flags().GetString("protection")
+		if protection == "" {
+			protection = config.Protection
+		}
+		protector, err := newProtector(&config, configPath, protection, sign)
+		if err != nil {
+			log.Printf("Error: %v\n", err)
+			return nil
+		}
+		opts = append(opts, services.WithProtector(protector))
+	}
+
 	emailService := services.NewDhanuEmailService(
 		config.SMTP.Host,
 		fmt.Sprintf("%d", config.SMTP.Port),
 		config.SMTP.FromEmail,
 		config.SMTP.Credentials,
+		opts...,
 	)
 
-	// Determine how to send the email based on the presence of attachments
-	if len(attachments) > 0 {
-		// Send email with attachments
-		err = emailService.SendDhanuEmailWithAttachments(
-			[]string{to}, // To recipients
-			subject,      // Subject
-			body,         // Body
-			false,        // isHtml flag (set to true for HTML content)
-			attachments,  // Attachments
-		)
+	// Build the message with all recipients, bodies, inline images and attachments
+	builder := services.NewMessage().
+		To(to...).
+		Cc(cc...).
+		Bcc(bcc...).
+		ReplyTo(replyTo...).
+		Subject(subject).
+		Text(textBody).
+		HTML(htmlBody).
+		Inline(inline...).
+		Attach(attachments...)
+	for _, header := range headers {
+		builder.Header(header.Name, header.Value)
+	}
+	if sign {
+		builder.Sign()
+	}
+	if encrypt {
+		builder.Encrypt()
+	}
+	msg, err := builder.Build()
+	if err != nil {
+		log.Printf("Error building email: %v\n", err)
+		return nil
+	}
+
+	// --save-eml and --dry-run need the exact bytes that are delivered, so the
+	// message is composed in memory first; otherwise it is streamed while sending.
+	saveEML, _ := cmd.Flags().GetString("save-eml")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	var data string
+	if saveEML != "" || dryRun {
+		if data, err = emailService.Compose(msg); err != nil {
+			log.Printf("Error building email: %v\n", err)
+			return nil
+		}
+	}
+	if saveEML != "" {
+		if err := os.WriteFile(saveEML, []byte(data), 0o644); err != nil {
+			log.Printf("Error saving email: %v\n", err)
+			return nil
+		}
+		log.Printf("Email saved to %s\n", saveEML)
+	}
+	if dryRun {
+		fmt.Fprintf(cmd.OutOrStdout(), "Envelope: from %s to %s\n\n", config.SMTP.FromEmail, strings.Join(msg.Envelope(), ", "))
+		if err := services.DescribeMessage(cmd.OutOrStdout(), data); err != nil {
+			log.Printf("Error describing email: %v\n", err)
+			return nil
+		}
+		log.Println("Dry run: email not sent.")
+		return nil
+	}
+
+	// Bound the whole send by --timeout when given
+	ctx := context.Background()
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Send the email
+	if data != "" {
+		err = emailService.SendRaw(ctx, msg.Envelope(), data)
 	} else {
-		// Send a simple email without attachments
-		err = emailService.SendDhanuEmail(
-			[]string{to}, // To recipients
-			subject,      // Subject
-			body,         // Body
-			false,        // isHtml flag (set to true for HTML content)
-		)
+		err = emailService.Send(ctx, msg)
 	}
 
 	// Handle sending errors
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("Error: sending timed out after %s: %v\n", timeout, err)
+		return err
+	}
+	if errors.Is(err, services.ErrTimeout) {
+		log.Printf("Error: SMTP server did not respond in time: %v\n", err)
+		return err
+	}
 	if err != nil {
 		log.Printf("Error sending email: %v\n", err)
-		return
+		return nil
 	}
 
 	log.Println("Email sent successfully.")
+	return nil
+}
+
+// newProtector creates the PGP/MIME or S/MIME protector described by the configuration.
+// Parameters:
+// - config: The loaded configuration.
+// - configPath: The configuration file path, next to which the keyring and certificates live by default.
+// - protection: services.ProtectionPGP or services.ProtectionSMIME; empty means PGP.
+// - sign: Whether the signing key is required.
+func newProtector(config *configs.Config, configPath, protection string, sign bool) (services.Protector, error) {
+	switch strings.ToLower(protection) {
+	case "", services.ProtectionPGP:
+		var signer *openpgp.Entity
+		if config.PGP.SigningKey != "" {
+			var err error
+			if signer, err = services.LoadPGPSigningKey(config.PGP.SigningKey, config.PGP.Passphrase); err != nil {
+				return nil, err
+			}
+		} else if sign {
+			return nil, errors.New("--sign requires pgp.signing_key in the configuration")
+		}
+		return services.NewPGPProtector(services.NewKeyring(keyringDir(config, configPath)), signer), nil
+
+	case services.ProtectionSMIME:
+		var signer *tls.Certificate
+		if config.SMIME.Certificate != "" {
+			pair, err := tls.LoadX509KeyPair(config.SMIME.Certificate, config.SMIME.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load S/MIME certificate: %v", err)
+			}
+			signer = &pair
+		} else if sign {
+			return nil, errors.New("--sign requires smime.certificate and smime.private_key in the configuration")
+		}
+		certDir := config.SMIME.Certificates
+		if certDir == "" {
+			certDir = filepath.Join(filepath.Dir(configPath), "certs")
+		}
+		return services.NewSMIMEProtector(services.NewCertStore(certDir), signer)
+
+	default:
+		return nil, fmt.Errorf("unknown protection %q (expected pgp or smime)", protection)
+	}
+}
+
+// newTransport creates the transport with the given name, or returns nil for SMTP.
+// Parameters:
+// - config: The loaded configuration.
+// - name: One of the services.Transport* names; empty means SMTP.
+func newTransport(config *configs.Config, name string) (services.Transport, error) {
+	switch strings.ToLower(name) {
+	case "", services.TransportSMTP:
+		return nil, nil
+	case services.TransportSendmail:
+		return services.NewSendmailTransport(config.Transport.SendmailPath), nil
+	case services.TransportFile, services.TransportMaildir:
+		if config.Transport.Dir == "" {
+			return nil, fmt.Errorf("the %s transport requires transport.dir in the configuration", name)
+		}
+		if strings.EqualFold(name, services.TransportMaildir) {
+			return services.NewMaildirTransport(config.Transport.Dir), nil
+		}
+		return services.NewFileTransport(config.Transport.Dir), nil
+	case services.TransportStdout:
+		return services.NewWriterTransport(os.Stdout), nil
+	case services.TransportSendGrid:
+		if config.SendGrid.APIKey == "" {
+			return nil, fmt.Errorf("the %s transport requires sendgrid.api_key in the configuration", name)
+		}
+		return services.NewSendGridTransport(config.SendGrid.APIKey, config.SendGrid.Endpoint), nil
+	case services.TransportMailgun:
+		if config.Mailgun.APIKey == "" || config.Mailgun.Domain == "" {
+			return nil, fmt.Errorf("the %s transport requires mailgun.api_key and mailgun.domain in the configuration", name)
+		}
+		return services.NewMailgunTransport(config.Mailgun.APIKey, config.Mailgun.Domain, config.Mailgun.Endpoint), nil
+	case services.TransportSES:
+		// Fall back to the standard AWS environment variables, as the AWS CLI does.
+		creds := services.AWSCredentials{
+			AccessKeyID:     firstNonEmpty(config.SES.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+			SecretAccessKey: firstNonEmpty(config.SES.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+			SessionToken:    firstNonEmpty(config.SES.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+		}
+		region := firstNonEmpty(config.SES.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+		if region == "" || creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, fmt.Errorf("the %s transport requires ses.region, ses.access_key_id and ses.secret_access_key in the configuration or the AWS environment", name)
+		}
+		return services.NewSESTransport(region, creds, config.SES.Endpoint), nil
+	case services.TransportPostmark:
+		if config.Postmark.ServerToken == "" {
+			return nil, fmt.Errorf("the %s transport requires postmark.server_token in the configuration", name)
+		}
+		return services.NewPostmarkTransport(config.Postmark.ServerToken, config.Postmark.MessageStream, config.Postmark.Endpoint), nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q (expected smtp, sendmail, file, maildir, stdout, sendgrid, mailgun, ses or postmark)", name)
+	}
+}
+
+// firstNonEmpty returns the first non-empty value.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
 }
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// writeTestConfig writes a configuration file and points DHANU_CONFIG at it.
+// Parameters:
+// - yaml: The configuration, with the sender already filled in.
+func writeTestConfig(t *testing.T, yaml string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dhanu.yaml")
+	config := "smtp:\n  from_email: sender@example.com\n  credentials: secret\n  security: none\n" + yaml
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DHANU_CONFIG", path)
+}
+
+// runSend runs "dhanu send" with the given flags and returns what it printed.
+func runSend(t *testing.T, args ...string) string {
+	t.Helper()
+	// Flags keep their values between executions, so restore the defaults.
+	t.Cleanup(func() {
+		sendCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				slice.Replace(nil)
+			} else {
+				flag.Value.Set(flag.DefValue)
+			}
+			flag.Changed = false
+		})
+	})
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	defer rootCmd.SetOut(nil)
+	rootCmd.SetArgs(append([]string{"send"}, args...))
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("dhanu send %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+// testAttachment writes a small file to attach.
+func testAttachment(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("name,total\na,1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSendDryRun(t *testing.T) {
+	// Count the connections made to the configured SMTP server.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var dials atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			dials.Add(1)
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	writeTestConfig(t, "  host: 127.0.0.1\n  port: "+port+"\n  io_timeout: 1\n")
+
+	out := runSend(t, "--to", "to@example.com", "--cc", "cc@example.com", "--bcc", "hidden@example.com",
+		"--subject", "Dry run", "--body", "Hello", "--attachments", testAttachment(t), "--dry-run")
+
+	// Give a connection that was made a moment to be accepted before counting.
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(100 * time.Millisecond))
+	<-done
+	if n := dials.Load(); n != 0 {
+		t.Errorf("dry run connected to the SMTP server %d times", n)
+	}
+
+	wantLines := []string{
+		"Envelope: from sender@example.com to to@example.com, cc@example.com, hidden@example.com\n",
+		"\nSubject: Dry run\n",
+		"\nMIME structure (",
+		"\n  multipart/mixed\n",
+		"\n    text/plain (charset=UTF-8, quoted-printable, 5 bytes)\n",
+		`attachment, "report.csv", 15 bytes)`,
+	}
+	for _, want := range wantLines {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry run output is missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasPrefix(out, wantLines[0]) {
+		t.Errorf("dry run output does not start with the envelope:\n%s", out)
+	}
+	if strings.Contains(out, "Bcc:") {
+		t.Errorf("dry run shows a Bcc header:\n%s", out)
+	}
+}
+
+func TestSendSaveEML(t *testing.T) {
+	// The file transport stores the delivered bytes, to compare the saved copy against.
+	outbox := filepath.Join(t.TempDir(), "outbox")
+	writeTestConfig(t, "transport:\n  type: file\n  dir: "+outbox+"\n")
+	saved := filepath.Join(t.TempDir(), "sent.eml")
+
+	runSend(t, "--to", "to@example.com", "--subject", "Saved", "--body", "Hello",
+		"--attachments", testAttachment(t), "--save-eml", saved)
+
+	entries, err := os.ReadDir(outbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("outbox holds %d messages, want 1", len(entries))
+	}
+	delivered, err := os.ReadFile(filepath.Join(outbox, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eml, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(eml, delivered) {
+		t.Errorf("saved message differs from the delivered one:\nsaved:\n%s\ndelivered:\n%s", eml, delivered)
+	}
+	if !bytes.Contains(eml, []byte("\r\nSubject: Saved\r\n")) {
+		t.Errorf("saved message is not the composed email:\n%s", eml)
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// apiMessage is a composed message taken apart again for the provider APIs
+// that accept structured JSON rather than raw MIME.
+type apiMessage struct {
+	From        *mail.Address
+	To          []*mail.Address
+	Cc          []*mail.Address
+	Bcc         []*mail.Address
+	ReplyTo     []*mail.Address
+	Subject     string
+	Text        string
+	HTML        string
+	Headers     []Header // Message-ID, X-Mailer and custom headers, in order.
+	Attachments []apiAttachment
+}
+
+// apiAttachment is an attachment or inline resource of an apiMessage.
+type apiAttachment struct {
+	Name        string
+	ContentType string
+	ContentID   string // Set for inline resources referenced from the HTML body.
+	Content     []byte
+}
+
+// apiSkippedHeaders are the header fields carried by an apiMessage's own
+// fields, or generated by the provider.
+var apiSkippedHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// parseAPIMessage takes a composed message apart. Recipients in the envelope
+// but not in the To or Cc headers become Bcc recipients. Signed, encrypted and
+// DKIM signed messages are refused, since re-assembling them would break the
+// signature. Custom header values are decoded from RFC 2047 encoded-words.
+// Parameters:
+// - provider: The transport name, used in errors.
+// - message: The complete message with CRLF line endings.
+// - envelope: The envelope recipients, including any Bcc addresses.
+func parseAPIMessage(provider, message string, envelope []string) (*apiMessage, error) {
+	parsed, err := mail.ReadMessage(strings.NewReader(message))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %v", err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/signed", "multipart/encrypted", "application/pkcs7-mime":
+		return nil, fmt.Errorf("the %s transport cannot send signed or encrypted email; use smtp, mailgun or ses", provider)
+	}
+	if parsed.Header.Get("DKIM-Signature") != "" {
+		return nil, fmt.Errorf("the %s transport rebuilds the message, which would break its DKIM signature; "+
+			"disable DKIM signing and let %s sign instead, or use smtp, mailgun or ses", provider, provider)
+	}
+
+	m := &apiMessage{}
+	if m.From, err = mail.ParseAddress(parsed.Header.Get("From")); err != nil {
+		return nil, fmt.Errorf("invalid From header: %v", err)
+	}
+	for _, list := range []struct {
+		name string
+		dest *[]*mail.Address
+	}{{"To", &m.To}, {"Cc", &m.Cc}, {"Reply-To", &m.ReplyTo}} {
+		addresses, err := parsed.Header.AddressList(list.name)
+		if err != nil && !errors.Is(err, mail.ErrHeaderNotPresent) {
+			return nil, fmt.Errorf("invalid %s header: %v", list.name, err)
+		}
+		*list.dest = addresses
+	}
+	visible := make(map[string]bool)
+	for _, address := range append(append([]*mail.Address{}, m.To...), m.Cc...) {
+		visible[strings.ToLower(address.Address)] = true
+	}
+	for _, recipient := range envelope {
+		bare := envelopeAddress(recipient)
+		if !visible[strings.ToLower(bare)] {
+			visible[strings.ToLower(bare)] = true
+			m.Bcc = append(m.Bcc, &mail.Address{Address: bare})
+		}
+	}
+
+	decoder := new(mime.WordDecoder)
+	if m.Subject, err = decoder.DecodeHeader(parsed.Header.Get("Subject")); err != nil {
+		m.Subject = parsed.Header.Get("Subject")
+	}
+	header, _, _ := strings.Cut(message, "\r\n\r\n")
+	for _, field := range splitHeaderFields(header + "\r\n") {
+		name := textproto.CanonicalMIMEHeaderKey(fieldName(field))
+		if apiSkippedHeaders[name] {
+			continue
+		}
+		_, value, _ := strings.Cut(field, ":")
+		value = strings.TrimSpace(strings.ReplaceAll(value, "\r\n", ""))
+		// The providers encode non-ASCII values themselves.
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		m.Headers = append(m.Headers, Header{Name: fieldName(field), Value: value})
+	}
+
+	body, err := io.ReadAll(parsed.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.addPart(textproto.MIMEHeader(parsed.Header), body); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// addPart stores a MIME entity as the text or HTML body or as an attachment,
+// recursing into multipart bodies.
+// Parameters:
+// - header: The entity's header fields.
+// - body: The entity's raw, still encoded body.
+func (m *apiMessage) addPart(header textproto.MIMEHeader, body []byte) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s part: %v", mediaType, err)
+			}
+			partBody, err := io.ReadAll(part)
+			if err != nil {
+				return fmt.Errorf("failed to read %s part: %v", mediaType, err)
+			}
+			if err := m.addPart(part.Header, partBody); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(transferDecoder(strings.ToLower(header.Get("Content-Transfer-Encoding")), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s part: %v", mediaType, err)
+	}
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	contentID := strings.Trim(header.Get("Content-Id"), "<>")
+
+	// The first undisposed text parts are the bodies; everything else is attached.
+	if disposition == "" && contentID == "" {
+		switch {
+		case mediaType == "text/plain" && m.Text == "":
+			m.Text = string(content)
+			return nil
+		case mediaType == "text/html" && m.HTML == "":
+			m.HTML = string(content)
+			return nil
+		}
+	}
+	name := dispParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if name == "" {
+		name = "attachment"
+	}
+	m.Attachments = append(m.Attachments, apiAttachment{
+		Name:        name,
+		ContentType: mediaType,
+		ContentID:   contentID,
+		Content:     content,
+	})
+	return nil
+}
+
+// transferDecoder returns a reader undoing a Content-Transfer-Encoding.
+// Parameters:
+// - encoding: The lower case Content-Transfer-Encoding; 7bit, 8bit and binary bodies are returned as they are.
+// - body: The encoded body.
+func transferDecoder(encoding string, body []byte) io.Reader {
+	switch encoding {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, bytes.NewReader(body))
+	case "quoted-printable":
+		return quotedprintable.NewReader(bytes.NewReader(body))
+	default:
+		return bytes.NewReader(body)
+	}
+}
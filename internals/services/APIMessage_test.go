@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseAPIMessageDecodesHeaders(t *testing.T) {
+	headers := []Header{
+		{Name: "X-Campaign", Value: "q3"},
+		{Name: "X-Greeting", Value: "Grüße aus München"},
+		{Name: "X-Long", Value: strings.TrimSpace(strings.Repeat("Übersicht नमस्ते ", 8))},
+	}
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret")
+	msg := &Message{
+		Recipients: Recipients{To: []string{"to@example.com"}},
+		Subject:    "Grüße",
+		TextBody:   "Hello",
+		Headers:    headers,
+	}
+	data, err := service.Compose(msg)
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	if !strings.Contains(data, "X-Greeting: =?") {
+		t.Fatalf("composed message does not encode the header:\n%s", data)
+	}
+
+	parsed, err := parseAPIMessage(TransportSendGrid, data, msg.Envelope())
+	if err != nil {
+		t.Fatalf("parseAPIMessage() error = %v", err)
+	}
+	if parsed.Subject != "Grüße" {
+		t.Errorf("subject = %q, want %q", parsed.Subject, "Grüße")
+	}
+	got := make(map[string]string)
+	for _, header := range parsed.Headers {
+		got[header.Name] = header.Value
+	}
+	for _, want := range headers {
+		if got[want.Name] != want.Value {
+			t.Errorf("%s = %q, want %q", want.Name, got[want.Name], want.Value)
+		}
+	}
+}
+
+func TestAPITransportsRejectDKIM(t *testing.T) {
+	key, err := GenerateDKIMKey(DKIMKeyEd25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewDKIMSigner("example.com", "mail", key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, newTransport := range map[string]func(endpoint string) Transport{
+		TransportSendGrid: func(endpoint string) Transport { return NewSendGridTransport("SG.test-key", endpoint) },
+		TransportPostmark: func(endpoint string) Transport { return NewPostmarkTransport("server-token", "", endpoint) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			standIn := newAPIStandIn(t, http.StatusOK, "{}")
+			service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret",
+				WithDKIM(signer),
+				WithTransport(newTransport(standIn.URL)),
+			)
+			err := service.Send(context.Background(), &Message{
+				Recipients: Recipients{To: []string{"to@example.com"}},
+				Subject:    "DKIM",
+				TextBody:   "Hello",
+			})
+			if err == nil || !strings.Contains(err.Error(), "DKIM") {
+				t.Fatalf("Send() error = %v, want one explaining the DKIM signature cannot be kept", err)
+			}
+			standIn.mu.Lock()
+			defer standIn.mu.Unlock()
+			if len(standIn.requests) != 0 {
+				t.Errorf("%s received %d requests, want none", name, len(standIn.requests))
+			}
+		})
+	}
+}
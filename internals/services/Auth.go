@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+const (
+	// AuthAuto negotiates the strongest mechanism the server advertises.
+	AuthAuto = "AUTO"
+	// AuthNone skips authentication entirely, for relays that accept unauthenticated mail.
+	AuthNone = "NONE"
+	// AuthPlain uses the PLAIN mechanism (RFC 4616).
+	AuthPlain = "PLAIN"
+	// AuthLogin uses the LOGIN mechanism required by Office365 and many corporate relays.
+	AuthLogin = "LOGIN"
+	// AuthCRAMMD5 uses the CRAM-MD5 challenge-response mechanism (RFC 2195).
+	AuthCRAMMD5 = "CRAM-MD5"
+	// AuthXOAUTH2 uses an OAuth 2.0 access token as the credentials.
+	AuthXOAUTH2 = "XOAUTH2"
+)
+
+// AuthFactory builds an smtp.Auth for a mechanism.
+// Parameters:
+// - username: The account to authenticate as.
+// - secret: The password, app password or access token.
+// - host: The SMTP host the credentials are meant for.
+type AuthFactory func(username, secret, host string) smtp.Auth
+
+var (
+	authMu       sync.RWMutex
+	authRegistry = map[string]AuthFactory{
+		AuthPlain: func(username, secret, host string) smtp.Auth {
+			return smtp.PlainAuth("", username, secret, host)
+		},
+		AuthLogin: func(username, secret, host string) smtp.Auth {
+			return &loginAuth{username: username, password: secret, host: host}
+		},
+		AuthCRAMMD5: func(username, secret, host string) smtp.Auth {
+			return smtp.CRAMMD5Auth(username, secret)
+		},
+		AuthXOAUTH2: func(username, secret, host string) smtp.Auth {
+			return &xoauth2Auth{username: username, token: secret}
+		},
+	}
+	// authPreference lists the mechanisms tried by AuthAuto, strongest first.
+	// XOAUTH2 is left out because it needs a token rather than a password.
+	authPreference = []string{AuthCRAMMD5, AuthPlain, AuthLogin}
+)
+
+// RegisterAuthMechanism adds or replaces an authentication mechanism in the registry.
+// Mechanisms registered this way can be selected by name but are not used by AuthAuto.
+// Parameters:
+// - name: The SASL mechanism name as advertised in the EHLO AUTH list.
+// - factory: The function that builds the smtp.Auth for the mechanism.
+func RegisterAuthMechanism(name string, factory AuthFactory) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	authRegistry[strings.ToUpper(name)] = factory
+}
+
+// ParseAuthMechanism validates a configured authentication mechanism.
+// An empty value is treated as AuthAuto.
+// Parameters:
+// - value: The configured mechanism name.
+func ParseAuthMechanism(value string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(value))
+	switch name {
+	case "":
+		return AuthAuto, nil
+	case AuthAuto, AuthNone:
+		return name, nil
+	}
+
+	authMu.RLock()
+	defer authMu.RUnlock()
+	if _, ok := authRegistry[name]; !ok {
+		return "", fmt.Errorf("unknown SMTP auth mechanism %q", value)
+	}
+	return name, nil
+}
+
+// selectAuth picks the smtp.Auth to use given the server's advertised AUTH list.
+// It returns nil when no authentication should be attempted.
+// Parameters:
+// - advertised: The parameters of the EHLO AUTH line, e.g. "PLAIN LOGIN".
+func (t *smtpTransport) selectAuth(advertised string) (smtp.Auth, error) {
+	mechanism := t.auth
+	if mechanism == "" {
+		mechanism = AuthAuto
+	}
+	if mechanism == AuthNone {
+		return nil, nil
+	}
+
+	offered := make(map[string]bool)
+	for _, name := range strings.Fields(advertised) {
+		offered[strings.ToUpper(name)] = true
+	}
+
+	authMu.RLock()
+	defer authMu.RUnlock()
+
+	if mechanism == AuthAuto {
+		// A relay that advertises no AUTH at all accepts unauthenticated mail.
+		if len(offered) == 0 {
+			return nil, nil
+		}
+		for _, name := range authPreference {
+			if offered[name] {
+				return authRegistry[name](t.username, t.credentials, t.host), nil
+			}
+		}
+		return nil, newSMTPError(ErrAuth, fmt.Errorf("server offers no supported mechanism (offered: %s)", advertised))
+	}
+
+	if !offered[mechanism] {
+		return nil, newSMTPError(ErrAuth, fmt.Errorf("server does not offer %s (offered: %s)", mechanism, advertised))
+	}
+	factory, ok := authRegistry[mechanism]
+	if !ok {
+		return nil, fmt.Errorf("unknown SMTP auth mechanism %q", mechanism)
+	}
+	return factory(t.username, t.credentials, t.host), nil
+}
+
+// isLocalhost reports whether the host refers to the local machine.
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// loginAuth implements the LOGIN mechanism.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like PLAIN, LOGIN sends the password in the clear, so require TLS off localhost.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism used by Gmail and Office365.
+type xoauth2Auth struct {
+	username string
+	token    string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	resp := "user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"
+	return "XOAUTH2", []byte(resp), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	// On failure the server sends a JSON error as a challenge; an empty
+	// response makes it finish with the final error reply.
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
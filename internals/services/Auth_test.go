@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"reflect"
+	"testing"
+)
+
+func TestSelectAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		mechanism  string
+		advertised string
+		want       string // Mechanism named by the selected smtp.Auth; "" for none.
+		wantErr    error
+	}{
+		{name: "auto prefers CRAM-MD5", mechanism: AuthAuto, advertised: "LOGIN PLAIN CRAM-MD5", want: AuthCRAMMD5},
+		{name: "auto prefers PLAIN over LOGIN", mechanism: AuthAuto, advertised: "LOGIN PLAIN", want: AuthPlain},
+		{name: "auto falls back to LOGIN", mechanism: AuthAuto, advertised: "XOAUTH2 login", want: AuthLogin},
+		{name: "auto skips XOAUTH2", mechanism: AuthAuto, advertised: "XOAUTH2", wantErr: ErrAuth},
+		{name: "auto without AUTH", mechanism: AuthAuto, advertised: ""},
+		{name: "unset means auto", mechanism: "", advertised: "PLAIN LOGIN", want: AuthPlain},
+		{name: "forced LOGIN", mechanism: AuthLogin, advertised: "PLAIN LOGIN CRAM-MD5", want: AuthLogin},
+		{name: "forced XOAUTH2", mechanism: AuthXOAUTH2, advertised: "PLAIN XOAUTH2", want: AuthXOAUTH2},
+		{name: "forced mechanism not offered", mechanism: AuthLogin, advertised: "PLAIN CRAM-MD5", wantErr: ErrAuth},
+		{name: "forced mechanism without AUTH", mechanism: AuthPlain, advertised: "", wantErr: ErrAuth},
+		{name: "none", mechanism: AuthNone, advertised: "PLAIN LOGIN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &smtpTransport{host: "localhost", username: "sender@example.com", credentials: "secret", auth: tt.mechanism}
+			auth, err := transport.selectAuth(tt.advertised)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("selectAuth(%q) error = %v, want %v", tt.advertised, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectAuth(%q) error = %v", tt.advertised, err)
+			}
+			if auth == nil {
+				if tt.want != "" {
+					t.Fatalf("selectAuth(%q) = nil, want %s", tt.advertised, tt.want)
+				}
+				return
+			}
+			got, _, err := auth.Start(&smtp.ServerInfo{Name: "localhost", TLS: true})
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("selectAuth(%q) chose %s, want %s", tt.advertised, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthExchange(t *testing.T) {
+	tests := []struct {
+		name      string
+		mechanism string
+		offered   []string
+		want      []fakeAuth
+		wantErr   error
+	}{
+		{
+			name:      "LOGIN",
+			mechanism: AuthLogin,
+			offered:   []string{"PLAIN", "LOGIN"},
+			want:      []fakeAuth{{Mechanism: "LOGIN", Responses: []string{"sender@example.com", "secret"}}},
+		},
+		{
+			name:      "XOAUTH2",
+			mechanism: AuthXOAUTH2,
+			offered:   []string{"PLAIN", "XOAUTH2"},
+			want:      []fakeAuth{{Mechanism: "XOAUTH2", Responses: []string{"user=sender@example.com\x01auth=Bearer secret\x01\x01"}}},
+		},
+		{
+			name:      "auto PLAIN",
+			mechanism: AuthAuto,
+			offered:   []string{"LOGIN", "PLAIN"},
+			want:      []fakeAuth{{Mechanism: "PLAIN", Responses: []string{"\x00sender@example.com\x00secret"}}},
+		},
+		{
+			name:      "none",
+			mechanism: AuthNone,
+			offered:   []string{"PLAIN", "LOGIN"},
+		},
+		{
+			name:      "forced mechanism not offered",
+			mechanism: AuthXOAUTH2,
+			offered:   []string{"PLAIN", "LOGIN"},
+			wantErr:   ErrAuth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTP{auth: tt.offered}
+			host, port := server.start(t)
+			service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+				WithSecurity(SecurityNone),
+				WithAuth(tt.mechanism),
+			)
+
+			err := service.Send(context.Background(), &Message{
+				Recipients: Recipients{To: []string{"rcpt@example.com"}},
+				Subject:    "Auth test",
+				TextBody:   "Hello",
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+				}
+				if auths := server.Auths(); len(auths) != 0 {
+					t.Errorf("server saw AUTH exchanges %q, want none", auths)
+				}
+				if messages := server.Messages(); len(messages) != 0 {
+					t.Errorf("server received %d messages, want none", len(messages))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if got := server.Auths(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AUTH exchanges = %q, want %q", got, tt.want)
+			}
+			if messages := server.Messages(); len(messages) != 1 {
+				t.Errorf("server received %d messages, want 1", len(messages))
+			}
+		})
+	}
+}
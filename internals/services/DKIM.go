@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DKIMKeyRSA selects RSA keys signed with rsa-sha256 (RFC 6376).
+	DKIMKeyRSA = "rsa"
+	// DKIMKeyEd25519 selects Ed25519 keys signed with ed25519-sha256 (RFC 8463).
+	DKIMKeyEd25519 = "ed25519"
+)
+
+// DefaultDKIMHeaders are the header fields signed when no list is configured.
+// Fields missing from a message are left out of its signature.
+var DefaultDKIMHeaders = []string{
+	"From", "To", "Cc", "Reply-To", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type",
+}
+
+// DKIMSigner adds a DKIM-Signature header to built messages, using relaxed
+// header and body canonicalization.
+type DKIMSigner struct {
+	domain   string
+	selector string
+	key      crypto.Signer
+	headers  []string
+}
+
+// NewDKIMSigner creates a DKIM signer.
+// Parameters:
+// - domain: The signing domain (d=), normally the sender's domain.
+// - selector: The selector (s=) under which the public key is published.
+// - key: An *rsa.PrivateKey or ed25519.PrivateKey.
+// - headers: The header fields to sign; nil uses DefaultDKIMHeaders. From is always signed.
+func NewDKIMSigner(domain, selector string, key crypto.Signer, headers []string) (*DKIMSigner, error) {
+	if domain == "" || selector == "" {
+		return nil, errors.New("DKIM requires a domain and a selector")
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+
+	if len(headers) == 0 {
+		headers = DefaultDKIMHeaders
+	}
+	// RFC 6376 section 5.4 requires the From field to be signed.
+	signed := []string{"From"}
+	for _, name := range headers {
+		name = strings.TrimSpace(name)
+		if name != "" && !strings.EqualFold(name, "From") {
+			signed = append(signed, name)
+		}
+	}
+	return &DKIMSigner{domain: domain, selector: selector, key: key, headers: signed}, nil
+}
+
+// LoadDKIMKey reads a PEM encoded private key in PKCS#1 (RSA) or PKCS#8 (RSA or Ed25519) form.
+// Parameters:
+// - path: The path of the key file.
+func LoadDKIMKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DKIM key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("DKIM key %s is not PEM encoded", path)
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DKIM key %s: %v", path, err)
+		}
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM key %s: %v", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+	return signer, nil
+}
+
+// GenerateDKIMKey creates a new DKIM private key.
+// Parameters:
+// - keyType: DKIMKeyRSA or DKIMKeyEd25519.
+// - bits: The RSA key size; ignored for Ed25519.
+func GenerateDKIMKey(keyType string, bits int) (crypto.Signer, error) {
+	switch strings.ToLower(keyType) {
+	case DKIMKeyRSA:
+		if bits < 1024 {
+			return nil, fmt.Errorf("RSA DKIM keys must have at least 1024 bits, got %d", bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case DKIMKeyEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unknown DKIM key type %q (expected rsa or ed25519)", keyType)
+	}
+}
+
+// MarshalDKIMKey encodes a private key as PKCS#8 PEM and its public key as PKIX PEM.
+// Parameters:
+// - key: The private key.
+func MarshalDKIMKey(key crypto.Signer) (privatePEM, publicPEM []byte, err error) {
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	privatePEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	return privatePEM, publicPEM, nil
+}
+
+// DKIMRecord returns the TXT record value that publishes the key's public half,
+// e.g. "v=DKIM1; k=rsa; p=MIIBIjANBg...".
+// Parameters:
+// - key: The private key.
+func DKIMRecord(key crypto.Signer) (string, error) {
+	switch public := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		// RFC 8463 publishes the raw 32-byte key rather than a PKIX structure.
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(public), nil
+	default:
+		return "", fmt.Errorf("unsupported DKIM key type %T", public)
+	}
+}
+
+// Sign returns the message with a DKIM-Signature header prepended.
+// Parameters:
+// - message: The complete message with CRLF line endings.
+func (s *DKIMSigner) Sign(message string) (string, error) {
+	header, body, found := strings.Cut(message, "\r\n\r\n")
+	if !found {
+		return "", errors.New("DKIM: message has no header/body separator")
+	}
+	header += "\r\n"
+
+	bodyHash := sha256.Sum256([]byte(relaxedBody(body)))
+
+	// Sign the selected fields, taking repeated fields from the bottom up (RFC 6376 section 5.4.2).
+	fields := splitHeaderFields(header)
+	used := make(map[int]bool)
+	var names []string
+	var canonical strings.Builder
+	for _, name := range s.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fieldName(fields[i]), name) {
+				continue
+			}
+			used[i] = true
+			names = append(names, name)
+			canonical.WriteString(relaxedHeader(fields[i]))
+			break
+		}
+	}
+
+	algorithm := "rsa-sha256"
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		algorithm = "ed25519-sha256"
+	}
+	tags := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		algorithm, s.domain, s.selector, time.Now().Unix(),
+		strings.Join(names, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	// The signature covers the DKIM-Signature field itself with an empty b= tag
+	// and without its trailing CRLF.
+	unsigned := relaxedHeader(formatHeader("DKIM-Signature", tags))
+	canonical.WriteString(strings.TrimSuffix(unsigned, "\r\n"))
+	digest := sha256.Sum256([]byte(canonical.String()))
+
+	var signature []byte
+	var err error
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		// RFC 8463 signs the SHA-256 digest with pure Ed25519.
+		signature = ed25519.Sign(key, digest[:])
+	default:
+		signature, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return "", fmt.Errorf("DKIM signing failed: %v", err)
+		}
+	}
+
+	// Split the signature into space separated chunks so the field can be folded.
+	encoded := base64.StdEncoding.EncodeToString(signature)
+	var chunks []string
+	for len(encoded) > 64 {
+		chunks = append(chunks, encoded[:64])
+		encoded = encoded[64:]
+	}
+	chunks = append(chunks, encoded)
+
+	return formatHeader("DKIM-Signature", tags+strings.Join(chunks, " ")) + message, nil
+}
+
+// splitHeaderFields splits a header block into fields, keeping folded
+// continuation lines with their field and each field's trailing CRLF.
+func splitHeaderFields(header string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// fieldName returns the name of a header field.
+func fieldName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+	return strings.TrimSpace(name)
+}
+
+// relaxedHeader applies the relaxed header canonicalization (RFC 6376 section 3.4.2):
+// lowercase name, unfolded value with whitespace runs reduced to one space and trimmed.
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.Join(strings.Fields(value), " ") + "\r\n"
+}
+
+// relaxedBody applies the relaxed body canonicalization (RFC 6376 section 3.4.4):
+// whitespace runs reduced to one space, trailing whitespace and trailing empty lines removed.
+func relaxedBody(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		var b strings.Builder
+		space := false
+		for _, r := range line {
+			if r == ' ' || r == '\t' {
+				space = true
+				continue
+			}
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+		lines[i] = b.String()
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// testDKIMKeys generates one key of each supported type.
+func testDKIMKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	keys := make(map[string]crypto.Signer)
+	for keyType, bits := range map[string]int{DKIMKeyRSA: 2048, DKIMKeyEd25519: 0} {
+		key, err := GenerateDKIMKey(keyType, bits)
+		if err != nil {
+			t.Fatalf("GenerateDKIMKey(%s) error = %v", keyType, err)
+		}
+		keys[keyType] = key
+	}
+	return keys
+}
+
+// verifyDKIM verifies the DKIM signatures of a message, looking the public
+// key up in the TXT record DKIMRecord returns for key.
+func verifyDKIM(t *testing.T, message string, key crypto.Signer) []*dkim.Verification {
+	t.Helper()
+	record, err := DKIMRecord(key)
+	if err != nil {
+		t.Fatalf("DKIMRecord() error = %v", err)
+	}
+	verifications, err := dkim.VerifyWithOptions(strings.NewReader(message), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			if domain != "mail._domainkey.example.com" {
+				return nil, errors.New("no such record")
+			}
+			return []string{record}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("dkim.Verify() error = %v", err)
+	}
+	return verifications
+}
+
+func TestDKIMSignVerifies(t *testing.T) {
+	for keyType, key := range testDKIMKeys(t) {
+		t.Run(keyType, func(t *testing.T) {
+			signer, err := NewDKIMSigner("example.com", "mail", key, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret",
+				WithFromName("Grüße Sender"),
+				WithDKIM(signer),
+			)
+			message, err := service.Compose(&Message{
+				Recipients: Recipients{To: []string{"a@example.com", "b@example.com"}},
+				Subject:    "DKIM " + strings.Repeat("long subject ", 10),
+				TextBody:   "Hello  world \r\n\r\n\r\n",
+				HTMLBody:   "<p>Hello</p>",
+			})
+			if err != nil {
+				t.Fatalf("Compose() error = %v", err)
+			}
+
+			verifications := verifyDKIM(t, message, key)
+			if len(verifications) != 1 {
+				t.Fatalf("message has %d DKIM signatures, want 1", len(verifications))
+			}
+			if err := verifications[0].Err; err != nil {
+				t.Fatalf("signature does not verify: %v\n%s", err, message)
+			}
+			if verifications[0].Domain != "example.com" {
+				t.Errorf("signature domain = %q, want example.com", verifications[0].Domain)
+			}
+
+			// Relaxed canonicalization tolerates refolded headers and
+			// changed whitespace, but not changed content.
+			header, body, _ := strings.Cut(message, "\r\n\r\n")
+			refolded := strings.Replace(header, "Subject: DKIM ", "Subject:   DKIM\r\n\t ", 1)
+			if refolded == header {
+				t.Fatalf("test message has no Subject to refold:\n%s", header)
+			}
+			respaced := strings.ReplaceAll(body, "\r\n", " \t\r\n") + "\r\n\r\n"
+			if err := verifyDKIM(t, refolded+"\r\n\r\n"+respaced, key)[0].Err; err != nil {
+				t.Errorf("signature does not survive whitespace changes: %v", err)
+			}
+			tampered := strings.Replace(message, "Hello", "Hallo", 1)
+			if err := verifyDKIM(t, tampered, key)[0].Err; err == nil {
+				t.Error("signature verifies after the content changed")
+			}
+		})
+	}
+}
+
+func TestDKIMSignedHeaders(t *testing.T) {
+	key := testDKIMKeys(t)[DKIMKeyEd25519]
+	tests := []struct {
+		name    string
+		headers []string
+		msg     *Message
+		want    string
+	}{
+		{
+			name: "defaults skip missing fields",
+			msg:  &Message{Recipients: Recipients{To: []string{"a@example.com"}}, Subject: "Hi", TextBody: "Hello"},
+			want: "From:To:Subject:Date:Message-ID:MIME-Version:Content-Type",
+		},
+		{
+			name: "defaults with Cc and Reply-To",
+			msg: &Message{
+				Recipients: Recipients{To: []string{"a@example.com"}, Cc: []string{"c@example.com"}, ReplyTo: []string{"r@example.com"}},
+				Subject:    "Hi",
+				TextBody:   "Hello",
+			},
+			want: "From:To:Cc:Reply-To:Subject:Date:Message-ID:MIME-Version:Content-Type",
+		},
+		{
+			name:    "configured list always signs From first",
+			headers: []string{"Subject", "from", "X-Campaign", "X-Missing"},
+			msg: &Message{
+				Recipients: Recipients{To: []string{"a@example.com"}},
+				Subject:    "Hi",
+				TextBody:   "Hello",
+				Headers:    []Header{{Name: "X-Campaign", Value: "spring"}},
+			},
+			want: "From:Subject:X-Campaign",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner("example.com", "mail", key, tt.headers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret", WithDKIM(signer))
+			message, err := service.Compose(tt.msg)
+			if err != nil {
+				t.Fatalf("Compose() error = %v", err)
+			}
+			if got := dkimTag(t, message, "h"); got != tt.want {
+				t.Errorf("h= %q, want %q", got, tt.want)
+			}
+			if got := dkimTag(t, message, "c"); got != "relaxed/relaxed" {
+				t.Errorf("c= %q, want relaxed/relaxed", got)
+			}
+			if err := verifyDKIM(t, message, key)[0].Err; err != nil {
+				t.Errorf("signature does not verify: %v", err)
+			}
+		})
+	}
+}
+
+// dkimTag returns the value of a tag of the message's DKIM-Signature field.
+func dkimTag(t *testing.T, message, tag string) string {
+	t.Helper()
+	field, _, _ := strings.Cut(message, "\r\n\r\n")
+	fields := splitHeaderFields(field + "\r\n")
+	if len(fields) == 0 || fieldName(fields[0]) != "DKIM-Signature" {
+		t.Fatalf("message does not start with a DKIM-Signature field:\n%s", message)
+	}
+	_, value, _ := strings.Cut(fields[0], ":")
+	for _, pair := range strings.Split(value, ";") {
+		name, tagValue, _ := strings.Cut(pair, "=")
+		if strings.TrimSpace(name) == tag {
+			return strings.Join(strings.Fields(tagValue), "")
+		}
+	}
+	t.Fatalf("DKIM-Signature has no %s= tag: %s", tag, fields[0])
+	return ""
+}
+
+func TestRelaxedCanonicalization(t *testing.T) {
+	headers := []struct{ field, want string }{
+		{field: "Subject: Hello\r\n", want: "subject:Hello\r\n"},
+		{field: "SUBJECT \t:  Hello \t  world  \r\n", want: "subject:Hello world\r\n"},
+		{field: "Subject: folded\r\n\tacross\r\n   lines\r\n", want: "subject:folded across lines\r\n"},
+		{field: "X-Empty:\r\n", want: "x-empty:\r\n"},
+	}
+	for _, tt := range headers {
+		if got := relaxedHeader(tt.field); got != tt.want {
+			t.Errorf("relaxedHeader(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+
+	bodies := []struct{ body, want string }{
+		{body: "", want: ""},
+		{body: "\r\n\r\n", want: ""},
+		{body: "Hello", want: "Hello\r\n"},
+		{body: "a  b\t\tc \t\r\n", want: "a b c\r\n"},
+		{body: " leading\r\n\r\nkept\r\n\r\n\r\n", want: " leading\r\n\r\nkept\r\n"},
+	}
+	for _, tt := range bodies {
+		if got := relaxedBody(tt.body); got != tt.want {
+			t.Errorf("relaxedBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDKIMKeygen(t *testing.T) {
+	for keyType, key := range testDKIMKeys(t) {
+		t.Run(keyType, func(t *testing.T) {
+			privatePEM, publicPEM, err := MarshalDKIMKey(key)
+			if err != nil {
+				t.Fatalf("MarshalDKIMKey() error = %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "dkim.pem")
+			if err := os.WriteFile(path, privatePEM, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadDKIMKey(path)
+			if err != nil {
+				t.Fatalf("LoadDKIMKey() error = %v", err)
+			}
+			if !loaded.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()) {
+				t.Error("loaded key differs from the generated one")
+			}
+
+			// The record must publish the same key as the .pub file.
+			block, _ := pem.Decode(publicPEM)
+			if block == nil || block.Type != "PUBLIC KEY" {
+				t.Fatalf("public key is not a PUBLIC KEY PEM block:\n%s", publicPEM)
+			}
+			public, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record, err := DKIMRecord(key)
+			if err != nil {
+				t.Fatalf("DKIMRecord() error = %v", err)
+			}
+			prefix := "v=DKIM1; k=" + keyType + "; p="
+			if !strings.HasPrefix(record, prefix) {
+				t.Fatalf("DKIMRecord() = %q, want prefix %q", record, prefix)
+			}
+			published, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(record, prefix))
+			if err != nil {
+				t.Fatalf("record key is not base64: %v", err)
+			}
+			switch public := public.(type) {
+			case *rsa.PublicKey:
+				if !bytes.Equal(published, block.Bytes) {
+					t.Error("record does not publish the PKIX encoded public key")
+				}
+			case ed25519.PublicKey:
+				if !bytes.Equal(published, public) {
+					t.Error("record does not publish the raw Ed25519 public key")
+				}
+			default:
+				t.Fatalf("unexpected public key type %T", public)
+			}
+		})
+	}
+}
@@ -2,23 +2,35 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
-	"net/smtp"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
 	"net/textproto"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/lordofthemind/dhanu/internals/utils"
 )
 
 // DhanuEmailService is responsible for handling email sending with various functionalities
 // such as sending plain text, HTML, attachments, and more.
 type DhanuEmailService struct {
-	smtpHost    string
-	smtpPort    string
-	fromEmail   string
-	credentials string
+	fromEmail string
+	fromName  string
+	smtp      *smtpTransport
+	transport Transport
+	retries   int
+	maxDelay  time.Duration
+	idDomain  string
+	dkim      *DKIMSigner
+	protector Protector
 }
 
 // NewDhanuEmailService creates a new instance of DhanuEmailService with the given SMTP configurations.
@@ -27,15 +39,253 @@ type DhanuEmailService struct {
 // - smtpPort: The port of the SMTP server.
 // - fromEmail: The sender's email address.
 // - credentials: The sender's credentials (e.g., password or app-specific token).
-func NewDhanuEmailService(smtpHost, smtpPort, fromEmail, credentials string) DhanuEmailServiceInterface {
-	return &DhanuEmailService{
-		smtpHost:    smtpHost,
-		smtpPort:    smtpPort,
-		fromEmail:   fromEmail,
-		credentials: credentials,
+// - opts: Optional settings such as the connection security mode.
+func NewDhanuEmailService(smtpHost, smtpPort, fromEmail, credentials string, opts ...DhanuEmailServiceOption) DhanuEmailServiceInterface {
+	es := &DhanuEmailService{
+		fromEmail: fromEmail,
+		smtp: &smtpTransport{
+			host:        smtpHost,
+			port:        smtpPort,
+			username:    fromEmail,
+			credentials: credentials,
+			security:    SecurityAuto,
+			auth:        AuthAuto,
+			ipFamily:    IPFamilyAuto,
+			dialTimeout: DefaultDialTimeout,
+			ioTimeout:   DefaultIOTimeout,
+		},
+		maxDelay: DefaultRetryMaxDelay,
+	}
+	for _, opt := range opts {
+		opt(es)
+	}
+	// Deliver over SMTP unless another transport was chosen.
+	if es.transport == nil {
+		es.transport = es.smtp
+	}
+	return es
+}
+
+// Send builds and delivers a message. When the transport can stream and the
+// message needs no signing or encryption, it is written straight to the
+// transport while it is built, so attachments are never held in memory.
+// Parameters:
+// - ctx: Cancels the send when done before the message is delivered.
+// - msg: The message to send.
+func (es *DhanuEmailService) Send(ctx context.Context, msg *Message) error {
+	if stream, ok := es.transport.(StreamTransport); ok && !es.needsComposing(msg) {
+		return es.sendStream(ctx, stream, msg)
+	}
+
+	data, err := es.Compose(msg)
+	if err != nil {
+		return err
+	}
+	return es.SendRaw(ctx, msg.Envelope(), data)
+}
+
+// SendRaw delivers an already composed message, e.g. one returned by Compose,
+// retrying transient failures.
+// Parameters:
+// - ctx: Cancels the send when done before the message is delivered.
+// - recipients: The envelope recipients, including any Bcc addresses.
+// - data: The complete message with CRLF line endings.
+func (es *DhanuEmailService) SendRaw(ctx context.Context, recipients []string, data string) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+	return es.withRetry(ctx, func() error {
+		return es.transport.Deliver(ctx, es.fromEmail, recipients, data)
+	})
+}
+
+// Close ends the SMTP sessions kept open by WithConnectionPool; later sends
+// fail with ErrServiceClosed. Without a pool it does nothing.
+func (es *DhanuEmailService) Close() error {
+	if es.smtp.pool != nil {
+		es.smtp.pool.close()
+	}
+	return nil
+}
+
+// sendStream builds the message while the transport delivers it. Everything
+// that can fail before the first byte is sent, such as invalid headers or a
+// missing attachment, is checked before connecting.
+// Parameters:
+// - ctx: Cancels the send when done before the message is delivered.
+// - transport: The streaming transport.
+// - msg: The message to send.
+func (es *DhanuEmailService) sendStream(ctx context.Context, transport StreamTransport, msg *Message) error {
+	if err := msg.Validate(); err != nil {
+		return fmt.Errorf("invalid email message: %w", err)
+	}
+	header, boundary, err := es.messageHeader(msg)
+	if err != nil {
+		return fmt.Errorf("failed to build email message: %w", err)
+	}
+	if err := checkAttachments(msg); err != nil {
+		return fmt.Errorf("failed to build email message: %w", err)
+	}
+
+	envelope := msg.Envelope()
+	write := func(w io.Writer) error {
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		return es.writeBody(w, msg, boundary)
+	}
+	// Tell the SMTP transport the approximate size, so it can check it
+	// against the server's limit without building the message twice. The
+	// body is all quoted-printable and base64, so only the header can need
+	// more than 7-bit ASCII.
+	if t, ok := transport.(*smtpTransport); ok {
+		info := messageInfo{size: streamedSize(msg, header), utf8Header: !isASCII(string(header))}
+		return es.withRetry(ctx, func() error {
+			return t.deliverStream(ctx, es.fromEmail, envelope, info, write)
+		})
+	}
+	return es.withRetry(ctx, func() error {
+		return transport.DeliverStream(ctx, es.fromEmail, envelope, write)
+	})
+}
+
+// streamedSize estimates the size of a message from its header, its encoded
+// text and the sizes of its files, without reading them. Part headers and
+// boundaries are left out, so the estimate never exceeds the real size.
+// Parameters:
+// - msg: The message, whose attachments have been checked.
+// - header: The message's header section.
+func streamedSize(msg *Message, header []byte) int64 {
+	size := int64(len(header))
+	for _, text := range []string{plainTextBody(msg), msg.HTMLBody} {
+		if text == "" {
+			continue
+		}
+		counter := &countingWriter{}
+		qpWriter := quotedprintable.NewWriter(counter)
+		qpWriter.Write([]byte(text))
+		qpWriter.Close()
+		size += counter.n
+	}
+	paths := append([]string{}, msg.Attachments...)
+	for _, resource := range msg.Inline {
+		paths = append(paths, resource.Path)
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			size += base64Length(info.Size())
+		}
+	}
+	return size
+}
+
+// withRetry runs deliver until it succeeds or fails permanently, retrying
+// transient failures with backoff up to the configured number of times.
+// Parameters:
+// - ctx: Cancels the send, including any wait between attempts.
+// - deliver: Makes one delivery attempt.
+func (es *DhanuEmailService) withRetry(ctx context.Context, deliver func() error) error {
+	// Don't start an SMTP session for a send the caller has already abandoned
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Deliver the email to every recipient, including Bcc, retrying transient failures
+	for attempt := 1; ; attempt++ {
+		err := deliver()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if attempt > es.retries {
+			if es.retries > 0 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		// Wait before the next attempt, unless the caller gives up first
+		timer := time.NewTimer(es.retryDelay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("email sending aborted: %w (%v)", ctx.Err(), err)
+		case <-timer.C:
+		}
 	}
 }
 
+// needsComposing reports whether the complete message must be built in
+// memory before it is sent: signatures and encryption cover the whole content,
+// and a DKIM signature header carries the hash of the whole body.
+// Parameters:
+// - msg: The message to send.
+func (es *DhanuEmailService) needsComposing(msg *Message) bool {
+	return msg.Sign || msg.Encrypt || es.dkim != nil
+}
+
+// Compose validates a message and returns the exact bytes Send would deliver,
+// signed and encrypted as requested and DKIM signed when configured, without
+// contacting any server.
+// Parameters:
+// - msg: The message to compose.
+func (es *DhanuEmailService) Compose(msg *Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", fmt.Errorf("invalid email message: %w", err)
+	}
+	if msg.Encrypt && len(msg.Bcc) > 0 {
+		return "", ErrEncryptedBcc
+	}
+
+	// Build the message
+	data, err := es.buildMessage(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to build email message: %w", err)
+	}
+
+	// Sign and/or encrypt the content when requested
+	if msg.Sign || msg.Encrypt {
+		if es.protector == nil {
+			return "", ErrNoProtector
+		}
+		if data, err = es.protector.Protect(data, msg.Envelope(), msg.Sign, msg.Encrypt); err != nil {
+			return "", err
+		}
+	}
+
+	// Sign the built message when DKIM is configured
+	if es.dkim != nil {
+		if data, err = es.dkim.Sign(data); err != nil {
+			return "", err
+		}
+	}
+	return data, nil
+}
+
+// WriteMessage validates a message and writes the bytes Send would deliver
+// to w. Unless the message must be signed, encrypted or DKIM signed, it is
+// streamed while it is built, reading attachments as they are written.
+// Parameters:
+// - w: The destination, e.g. a file.
+// - msg: The message to write.
+func (es *DhanuEmailService) WriteMessage(w io.Writer, msg *Message) error {
+	if es.needsComposing(msg) {
+		data, err := es.Compose(msg)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, data)
+		return err
+	}
+
+	if err := msg.Validate(); err != nil {
+		return fmt.Errorf("invalid email message: %w", err)
+	}
+	if err := es.writeMessage(w, msg); err != nil {
+		return fmt.Errorf("failed to build email message: %w", err)
+	}
+	return nil
+}
+
 // SendDhanuEmail sends a plain text or HTML email based on the isHTML flag.
 // Parameters:
 // - to: The list of recipients.
@@ -43,14 +293,7 @@ func NewDhanuEmailService(smtpHost, smtpPort, fromEmail, credentials string) Dha
 // - body: The content of the email.
 // - isHTML: Flag to specify whether the email is in HTML format or plain text.
 func (es *DhanuEmailService) SendDhanuEmail(to []string, subject, body string, isHTML bool) error {
-	// Build the message
-	msg, err := es.buildMessage(to, subject, body, isHTML, nil)
-	if err != nil {
-		return fmt.Errorf("failed to build email message: %v", err)
-	}
-
-	// Send the email
-	return es.send(msg, to)
+	return es.SendDhanuEmailToRecipients(Recipients{To: to}, subject, body, isHTML, nil, nil)
 }
 
 // SendDhanuEmailWithAttachments sends an email with or without HTML and includes attachments.
@@ -61,65 +304,309 @@ func (es *DhanuEmailService) SendDhanuEmail(to []string, subject, body string, i
 // - isHTML: Flag to specify whether the email is in HTML format or plain text.
 // - attachments: A list of file paths to attach to the email.
 func (es *DhanuEmailService) SendDhanuEmailWithAttachments(to []string, subject, body string, isHTML bool, attachments []string) error {
-	// Build the message with attachments
-	msg, err := es.buildMessage(to, subject, body, isHTML, attachments)
-	if err != nil {
-		return fmt.Errorf("failed to build email message with attachments: %v", err)
-	}
-
-	// Send the email
-	return es.send(msg, to)
+	return es.SendDhanuEmailToRecipients(Recipients{To: to}, subject, body, isHTML, attachments, nil)
 }
 
-// buildMessage constructs the email message.
+// SendDhanuEmailWithInlineResources sends an HTML email with embedded inline
+// resources (such as images referenced via "cid:" URLs) and optional attachments.
 // Parameters:
 // - to: The list of recipients.
 // - subject: The subject of the email.
+// - htmlBody: The HTML content of the email.
+// - attachments: A list of file paths to attach to the email.
+// - inline: The resources referenced from the HTML by Content-ID.
+func (es *DhanuEmailService) SendDhanuEmailWithInlineResources(to []string, subject, htmlBody string, attachments []string, inline []InlineResource) error {
+	return es.SendDhanuEmailToRecipients(Recipients{To: to}, subject, htmlBody, true, attachments, inline)
+}
+
+// SendDhanuEmailToRecipients sends an email to To, Cc and Bcc recipients with an optional Reply-To.
+// Bcc recipients receive the message through the SMTP envelope only.
+// Parameters:
+// - recipients: The To, Cc, Bcc and Reply-To addresses.
+// - subject: The subject of the email.
 // - body: The content of the email.
 // - isHTML: Flag to specify whether the email is in HTML format or plain text.
 // - attachments: A list of file paths to attach to the email (optional).
-func (es *DhanuEmailService) buildMessage(to []string, subject, body string, isHTML bool, attachments []string) (string, error) {
+// - inline: Resources referenced from an HTML body by Content-ID (optional).
+func (es *DhanuEmailService) SendDhanuEmailToRecipients(recipients Recipients, subject, body string, isHTML bool, attachments []string, inline []InlineResource) error {
+	msg := &Message{
+		Recipients:  recipients,
+		Subject:     subject,
+		Attachments: attachments,
+		Inline:      inline,
+	}
+	if isHTML {
+		msg.HTMLBody = body
+	} else {
+		msg.TextBody = body
+	}
+	return es.Send(context.Background(), msg)
+}
+
+// buildMessage constructs the email message in memory.
+// Parameters:
+// - msg: The message to construct.
+func (es *DhanuEmailService) buildMessage(msg *Message) (string, error) {
+	var b strings.Builder
+	if err := es.writeMessage(&b, msg); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeMessage writes the complete message to w.
+// Parameters:
+// - w: The destination.
+// - msg: The message to write.
+func (es *DhanuEmailService) writeMessage(w io.Writer, msg *Message) error {
+	header, boundary, err := es.messageHeader(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	return es.writeBody(w, msg, boundary)
+}
+
+// messageHeader validates every header-bound value of a message and returns
+// its header section, ending with the blank line, and the boundary of its
+// multipart/mixed body. Bcc addresses are deliberately not written to the headers.
+// Parameters:
+// - msg: The message to construct.
+func (es *DhanuEmailService) messageHeader(msg *Message) ([]byte, string, error) {
 	var buffer bytes.Buffer
+	boundary := randomBoundary()
 
-	// Create a new MIME multipart writer.
-	writer := multipart.NewWriter(&buffer)
+	// Format the address headers, encoding display names where needed.
+	if es.fromEmail == "" {
+		return nil, "", fmt.Errorf("sender address is not configured")
+	}
+	if err := checkAddress(es.fromEmail); err != nil {
+		return nil, "", err
+	}
+	from := (&mail.Address{Name: sanitizeHeaderText(es.fromName), Address: es.fromEmail}).String()
+	if msg.From != "" {
+		var err error
+		if from, err = formatAddress(msg.From); err != nil {
+			return nil, "", err
+		}
+	}
+	toList, err := formatAddressList(msg.To)
+	if err != nil {
+		return nil, "", err
+	}
+	ccList, err := formatAddressList(msg.Cc)
+	if err != nil {
+		return nil, "", err
+	}
+	replyToList, err := formatAddressList(msg.ReplyTo)
+	if err != nil {
+		return nil, "", err
+	}
 
-	// Set email headers.
-	contentType := "text/plain"
-	if isHTML {
-		contentType = "text/html"
+	// Validate custom headers and Content-IDs before anything is written.
+	for _, header := range msg.Headers {
+		if err := validateCustomHeader(header.Name, header.Value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, resource := range msg.Inline {
+		if err := validateMsgID("Content-ID", resource.ContentID); err != nil {
+			return nil, "", err
+		}
 	}
 
-	// Write headers: From, To, Subject.
+	// Generate a Message-ID unless the caller supplied one. The message is
+	// left untouched, so re-sending it or sending it concurrently gets fresh IDs.
+	messageID := msg.MessageID
+	if messageID == "" {
+		if messageID, err = newMessageID(es.messageIDDomain()); err != nil {
+			return nil, "", err
+		}
+	} else if err := validateMsgID("Message-ID", messageID); err != nil {
+		return nil, "", err
+	}
+
+	// Write headers: Date, Message-ID, From, To, Cc, Reply-To, Subject, folded and RFC 2047 encoded.
+	// Custom headers may override Date and X-Mailer.
 	buffer.WriteString("MIME-Version: 1.0\r\n")
-	buffer.WriteString(fmt.Sprintf("From: %s\r\n", es.fromEmail))
-	buffer.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(to, ",")))
-	buffer.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	buffer.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary()))
+	if !hasHeader(msg.Headers, "Date") {
+		buffer.WriteString(formatHeader("Date", time.Now().Format(time.RFC1123Z)))
+	}
+	buffer.WriteString(formatHeader("Message-ID", "<"+messageID+">"))
+	buffer.WriteString(formatHeader("From", from))
+	if toList != "" {
+		buffer.WriteString(formatHeader("To", toList))
+	}
+	if ccList != "" {
+		buffer.WriteString(formatHeader("Cc", ccList))
+	}
+	if replyToList != "" {
+		buffer.WriteString(formatHeader("Reply-To", replyToList))
+	}
+	buffer.WriteString(formatHeader("Subject", encodeHeaderText(sanitizeHeaderText(msg.Subject))))
+	if !hasHeader(msg.Headers, "X-Mailer") {
+		buffer.WriteString(formatHeader("X-Mailer", mailerName))
+	}
+	for _, header := range msg.Headers {
+		buffer.WriteString(formatHeader(header.Name, encodeHeaderText(header.Value)))
+	}
+	buffer.WriteString(formatHeader("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%s", boundary)))
+	if err := checkHeaderBlock(buffer.Bytes()); err != nil {
+		return nil, "", err
+	}
+	buffer.WriteString("\r\n")
+	return buffer.Bytes(), boundary, nil
+}
 
-	// Add the email body part.
-	bodyPartHeader := make(textproto.MIMEHeader)
-	bodyPartHeader.Set("Content-Type", fmt.Sprintf("%s; charset=UTF-8", contentType))
-	bodyPartHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+// writeBody writes the multipart/mixed body of a message, reading
+// attachments and inline resources as they are written.
+// Parameters:
+// - w: The destination.
+// - msg: The message to write.
+// - boundary: The boundary announced in the message header.
+func (es *DhanuEmailService) writeBody(w io.Writer, msg *Message, boundary string) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return err
+	}
 
-	bodyPart, err := writer.CreatePart(bodyPartHeader)
+	// Add the email body: plain text on its own, or HTML together with
+	// a plain-text rendering in a multipart/alternative part. Inline
+	// resources are grouped with the HTML in a multipart/related part.
+	textBody := plainTextBody(msg)
+	var err error
+	switch {
+	case msg.HTMLBody != "" && len(msg.Inline) > 0:
+		err = es.addRelatedBody(writer, textBody, msg.HTMLBody, msg.Inline)
+	case msg.HTMLBody != "":
+		err = es.addAlternativeBody(writer, textBody, msg.HTMLBody)
+	default:
+		err = es.addTextPart(writer, "text/plain", textBody)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create email body part: %v", err)
+		return err
 	}
-	bodyPart.Write([]byte(body)) // Add the body content.
 
 	// Handle attachments if any.
-	for _, attachment := range attachments {
+	for _, attachment := range msg.Attachments {
 		err = es.addAttachment(writer, attachment)
 		if err != nil {
-			return "", fmt.Errorf("failed to add attachment: %v", err)
+			return fmt.Errorf("failed to add attachment: %w", err)
 		}
 	}
 
 	// Close the multipart writer to finalize the message.
-	writer.Close()
+	return writer.Close()
+}
+
+// plainTextBody returns the plain-text body of a message, rendered from the
+// HTML body when the message has none.
+// Parameters:
+// - msg: The message.
+func plainTextBody(msg *Message) string {
+	if msg.TextBody == "" && msg.HTMLBody != "" {
+		return utils.HTMLToText(msg.HTMLBody)
+	}
+	return msg.TextBody
+}
+
+// addTextPart adds a quoted-printable encoded text part to the email.
+// Parameters:
+// - writer: The MIME multipart writer.
+// - contentType: The media type of the text, e.g. "text/plain" or "text/html".
+// - body: The text content.
+func (es *DhanuEmailService) addTextPart(writer *multipart.Writer, contentType, body string) error {
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Type", fmt.Sprintf("%s; charset=UTF-8", contentType))
+	partHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return fmt.Errorf("failed to create email body part: %v", err)
+	}
+
+	// Encode the body content as quoted-printable.
+	qpWriter := quotedprintable.NewWriter(part)
+	if _, err := qpWriter.Write([]byte(body)); err != nil {
+		return fmt.Errorf("failed to write email body: %v", err)
+	}
+	if err := qpWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write email body: %v", err)
+	}
+	return nil
+}
+
+// addAlternativeBody adds a multipart/alternative part holding the plain-text
+// and HTML renderings of the body, in increasing order of preference.
+// Parameters:
+// - writer: The MIME multipart writer.
+// - textBody: The plain-text rendering.
+// - htmlBody: The HTML rendering.
+func (es *DhanuEmailService) addAlternativeBody(writer *multipart.Writer, textBody, htmlBody string) error {
+	alternative, err := createNestedMultipart(writer, "alternative", nil)
+	if err != nil {
+		return err
+	}
+	if err := es.addTextPart(alternative, "text/plain", textBody); err != nil {
+		return err
+	}
+	if err := es.addTextPart(alternative, "text/html", htmlBody); err != nil {
+		return err
+	}
+	return alternative.Close()
+}
 
-	return buffer.String(), nil
+// addRelatedBody adds a multipart/related part holding the alternative body
+// followed by the inline resources the HTML references by Content-ID.
+// Parameters:
+// - writer: The MIME multipart writer.
+// - textBody: The plain-text rendering.
+// - htmlBody: The HTML rendering.
+// - inline: The resources referenced from the HTML.
+func (es *DhanuEmailService) addRelatedBody(writer *multipart.Writer, textBody, htmlBody string, inline []InlineResource) error {
+	related, err := createNestedMultipart(writer, "related", map[string]string{"type": "multipart/alternative"})
+	if err != nil {
+		return err
+	}
+	if err := es.addAlternativeBody(related, textBody, htmlBody); err != nil {
+		return err
+	}
+	for _, resource := range inline {
+		if err := es.addInlineResource(related, resource); err != nil {
+			return err
+		}
+	}
+	return related.Close()
+}
+
+// createNestedMultipart creates a multipart part of the given subtype inside
+// writer and returns a writer for its children. The caller must close it.
+// Parameters:
+// - writer: The parent MIME multipart writer.
+// - subtype: The multipart subtype, e.g. "alternative" or "related".
+// - params: Additional Content-Type parameters (optional).
+func createNestedMultipart(writer *multipart.Writer, subtype string, params map[string]string) (*multipart.Writer, error) {
+	// Generate the nested boundary up front, since it goes into the part header.
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	contentParams := map[string]string{"boundary": boundary}
+	for key, value := range params {
+		contentParams[key] = value
+	}
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Type", mime.FormatMediaType("multipart/"+subtype, contentParams))
+	part, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart/%s part: %v", subtype, err)
+	}
+
+	nested := multipart.NewWriter(part)
+	if err := nested.SetBoundary(boundary); err != nil {
+		return nil, fmt.Errorf("failed to create multipart/%s part: %v", subtype, err)
+	}
+	return nested, nil
 }
 
 // addAttachment adds a file as an attachment to the email.
@@ -130,17 +617,21 @@ func (es *DhanuEmailService) addAttachment(writer *multipart.Writer, filePath st
 	// Open the file to attach.
 	file, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open attachment: %v", err)
+		return attachmentError(filePath, err)
 	}
 	defer file.Close()
 
 	// Get the file name and its MIME type.
-	_, fileName := filepath.Split(filePath)
-	mimeType := "application/octet-stream" // Generic binary stream for the zip file.
+	fileName := sanitizeFileName(filepath.Base(filePath))
+	mimeType, err := detectContentType(file, fileName)
+	if err != nil {
+		return attachmentError(filePath, err)
+	}
 
-	// Create a header for the attachment part.
+	// Create a header for the attachment part. FormatMediaType quotes the
+	// file name and switches to an RFC 2231 filename* parameter when needed.
 	attachmentHeader := make(textproto.MIMEHeader)
-	attachmentHeader.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	attachmentHeader.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
 	attachmentHeader.Set("Content-Type", mimeType)
 	attachmentHeader.Set("Content-Transfer-Encoding", "base64")
 
@@ -150,23 +641,59 @@ func (es *DhanuEmailService) addAttachment(writer *multipart.Writer, filePath st
 		return fmt.Errorf("failed to create attachment part: %v", err)
 	}
 
-	// Copy the file content to the attachment part.
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return fmt.Errorf("failed to write attachment content: %v", err)
+	// Stream the file content into the attachment part as base64.
+	if err := writeBase64(part, file); err != nil {
+		return attachmentError(filePath, err)
 	}
 
 	return nil
 }
 
-// send handles the actual sending of the email through SMTP.
+// checkAttachments verifies that every attachment and inline resource can be
+// opened, so a streamed message does not fail halfway through.
 // Parameters:
-// - msg: The constructed email message.
-// - to: The list of recipients.
-func (es *DhanuEmailService) send(msg string, to []string) error {
-	auth := smtp.PlainAuth("", es.fromEmail, es.credentials, es.smtpHost)
+// - msg: The message to check.
+func checkAttachments(msg *Message) error {
+	paths := append([]string{}, msg.Attachments...)
+	for _, resource := range msg.Inline {
+		paths = append(paths, resource.Path)
+	}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return attachmentError(path, err)
+		}
+		file.Close()
+	}
+	return nil
+}
 
-	// Use SMTPS (secure SMTP) to send the email with strong authentication
-	addr := fmt.Sprintf("%s:%s", es.smtpHost, es.smtpPort)
-	return smtp.SendMail(addr, auth, es.fromEmail, to, []byte(msg))
+// detectContentType determines the MIME type of an attachment, first from the
+// file extension and then by sniffing the leading bytes of the content.
+// The file is rewound to the start before returning.
+// Parameters:
+// - file: The opened attachment.
+// - fileName: The attachment's file name, used for the extension and the name parameter.
+func detectContentType(file *os.File, fileName string) (string, error) {
+	mediaType := mime.TypeByExtension(filepath.Ext(fileName))
+	if mediaType == "" {
+		// http.DetectContentType considers at most the first 512 bytes.
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		mediaType = http.DetectContentType(head[:n])
+	}
+
+	// Re-format the type so the name parameter is quoted or encoded like the filename.
+	baseType, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		baseType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = fileName
+	return mime.FormatMediaType(baseType, params), nil
 }
@@ -1,10 +1,36 @@
 package services
 
+import (
+	"context"
+	"io"
+)
+
 // DhanuEmailServiceInterface defines the interface for sending Dhanu emails.
 type DhanuEmailServiceInterface interface {
+	// Send builds and delivers a message.
+	Send(ctx context.Context, msg *Message) error
+
+	// Compose validates and builds a message, returning the exact bytes Send would deliver.
+	Compose(msg *Message) (string, error)
+
+	// WriteMessage validates and writes a message to w, streaming it when possible.
+	WriteMessage(w io.Writer, msg *Message) error
+
+	// SendRaw delivers an already composed message to the given envelope recipients.
+	SendRaw(ctx context.Context, recipients []string, data string) error
+
+	// Close ends any pooled SMTP sessions.
+	Close() error
+
 	// SendDhanuEmail sends a plain text or HTML email based on the isHTML flag.
 	SendDhanuEmail(to []string, subject, body string, isHTML bool) error
 
 	// SendDhanuEmailWithAttachments sends an email with or without HTML and includes attachments.
 	SendDhanuEmailWithAttachments(to []string, subject, body string, isHTML bool, attachments []string) error
+
+	// SendDhanuEmailWithInlineResources sends an HTML email with inline resources referenced by Content-ID.
+	SendDhanuEmailWithInlineResources(to []string, subject, htmlBody string, attachments []string, inline []InlineResource) error
+
+	// SendDhanuEmailToRecipients sends an email to To, Cc and Bcc recipients with an optional Reply-To.
+	SendDhanuEmailToRecipients(recipients Recipients, subject, body string, isHTML bool, attachments []string, inline []InlineResource) error
 }
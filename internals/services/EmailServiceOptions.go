@@ -0,0 +1,169 @@
+package services
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// DhanuEmailServiceOption configures optional behaviour of DhanuEmailService.
+type DhanuEmailServiceOption func(*DhanuEmailService)
+
+// WithSecurity sets how the connection to the SMTP server is secured.
+// Parameters:
+// - mode: The security mode (implicit TLS, STARTTLS, opportunistic STARTTLS or none).
+func WithSecurity(mode SecurityMode) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.security = mode
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for implicit TLS and STARTTLS.
+// The ServerName defaults to the SMTP host when left empty.
+// Parameters:
+// - config: The TLS configuration to use.
+func WithTLSConfig(config *tls.Config) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.tlsConfig = config
+	}
+}
+
+// WithAuth sets the SMTP authentication mechanism.
+// Parameters:
+// - mechanism: A registered mechanism name, AuthAuto or AuthNone (see ParseAuthMechanism).
+func WithAuth(mechanism string) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.auth = mechanism
+	}
+}
+
+// WithFromName sets the display name shown in the From header.
+// Parameters:
+// - name: The sender's display name, encoded per RFC 2047 when it is not ASCII.
+func WithFromName(name string) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.fromName = name
+	}
+}
+
+// WithTimeouts sets the SMTP dial and per-operation I/O timeouts.
+// A zero duration leaves the corresponding default in place.
+// Parameters:
+// - dial: The maximum time to establish the TCP (and implicit TLS) connection.
+// - io: The maximum time any single read or write on the connection may take.
+func WithTimeouts(dial, io time.Duration) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		if dial > 0 {
+			es.smtp.dialTimeout = dial
+		}
+		if io > 0 {
+			es.smtp.ioTimeout = io
+		}
+	}
+}
+
+// WithRetry makes Send retry transient failures (4xx replies, timeouts and
+// connection errors) with jittered exponential backoff. Permanent 5xx
+// rejections are never retried.
+// Parameters:
+// - retries: The number of additional attempts after the first one.
+// - maxDelay: The ceiling for the delay between attempts; zero keeps the default.
+func WithRetry(retries int, maxDelay time.Duration) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.retries = retries
+		if maxDelay > 0 {
+			es.maxDelay = maxDelay
+		}
+	}
+}
+
+// WithMessageIDDomain sets the domain used in generated Message-ID headers.
+// By default the domain of the sender's address is used.
+// Parameters:
+// - domain: The domain, e.g. "mail.example.com".
+func WithMessageIDDomain(domain string) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.idDomain = domain
+	}
+}
+
+// WithDKIM signs every message with DKIM after it is built.
+// Parameters:
+// - signer: The signer created with NewDKIMSigner.
+func WithDKIM(signer *DKIMSigner) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.dkim = signer
+	}
+}
+
+// WithProtector sets how messages marked for signing or encryption are protected.
+// Parameters:
+// - protector: The protector, e.g. one created with NewPGPProtector.
+func WithProtector(protector Protector) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.protector = protector
+	}
+}
+
+// WithTransport delivers messages through another transport instead of SMTP,
+// e.g. a sendmail binary or a directory of .eml files.
+// Parameters:
+// - transport: The transport to deliver with.
+func WithTransport(transport Transport) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.transport = transport
+	}
+}
+
+// WithConnectionPool keeps authenticated SMTP sessions open between messages
+// instead of connecting for each one, for sending many messages quickly.
+// Sessions are reset with RSET before each message and replaced when the
+// server closes them. The service may then be used from several goroutines
+// at once; call Close when done to end the open sessions.
+// Parameters:
+// - size: The maximum number of sessions open at once, i.e. of messages sent in parallel.
+// - idleTimeout: How long a session may sit unused before it is closed; zero uses DefaultPoolIdleTimeout.
+func WithConnectionPool(size int, idleTimeout time.Duration) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.pool = newSMTPPool(size, idleTimeout)
+	}
+}
+
+// WithDSN requests delivery status notifications for every message, from
+// SMTP servers that advertise the DSN extension.
+// Parameters:
+// - dsn: When to notify and what failure reports include, e.g. from ParseDSN.
+func WithDSN(dsn DSNOptions) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.dsn = dsn
+	}
+}
+
+// WithHelloName sets the name the service introduces itself with in EHLO or
+// HELO. By default the machine's fully qualified domain name is used, or the
+// local IP address when it has none.
+// Parameters:
+// - name: The host name, e.g. "mailer.example.com".
+func WithHelloName(name string) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.hello = name
+	}
+}
+
+// WithLocalAddress makes SMTP connections originate from a specific local
+// IP address or network interface, e.g. the one listed in the domain's SPF record.
+// Parameters:
+// - address: An IP address such as "192.0.2.10", or an interface name such as "eth1".
+func WithLocalAddress(address string) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.localAddr = address
+	}
+}
+
+// WithIPFamily restricts SMTP connections to IPv4 or IPv6.
+// Parameters:
+// - family: IPFamilyAuto, IPFamilyIPv4 or IPFamilyIPv6.
+func WithIPFamily(family IPFamily) DhanuEmailServiceOption {
+	return func(es *DhanuEmailService) {
+		es.smtp.ipFamily = family
+	}
+}
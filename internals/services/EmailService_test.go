@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// decodedPart is a leaf part of a parsed message with its transfer encoding undone.
+type decodedPart struct {
+	ContentType string
+	FileName    string
+	Body        []byte
+}
+
+// parseMessage parses a message built by the service and returns its leaf
+// parts in order, descending into nested multiparts.
+func parseMessage(t *testing.T, data []byte) (*mail.Message, []decodedPart) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("net/mail cannot parse the message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, want multipart/mixed", msg.Header.Get("Content-Type"))
+	}
+	return msg, readParts(t, msg.Body, params["boundary"])
+}
+
+func readParts(t *testing.T, r io.Reader, boundary string) []decodedPart {
+	t.Helper()
+	var parts []decodedPart
+	reader := multipart.NewReader(r, boundary)
+	for {
+		// NextRawPart leaves quoted-printable undecoded, so the test checks the encoding itself.
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("failed to read MIME part: %v", err)
+		}
+		mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("invalid part Content-Type %q: %v", part.Header.Get("Content-Type"), err)
+		}
+		if strings.HasPrefix(mediaType, "multipart/") {
+			parts = append(parts, readParts(t, part, params["boundary"])...)
+			continue
+		}
+
+		raw, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("failed to read %s part: %v", mediaType, err)
+		}
+		for _, line := range strings.Split(string(raw), "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("%s part has a %d-character line", mediaType, len(line))
+			}
+		}
+
+		var body []byte
+		switch encoding := part.Header.Get("Content-Transfer-Encoding"); encoding {
+		case "quoted-printable":
+			body, err = io.ReadAll(quotedprintable.NewReader(bytes.NewReader(raw)))
+		case "base64":
+			body, err = io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw)))
+		default:
+			t.Fatalf("%s part has unexpected transfer encoding %q", mediaType, encoding)
+		}
+		if err != nil {
+			t.Fatalf("failed to decode %s part: %v", mediaType, err)
+		}
+
+		decoded := decodedPart{ContentType: mediaType, Body: body}
+		if disposition := part.Header.Get("Content-Disposition"); disposition != "" {
+			_, dispositionParams, err := mime.ParseMediaType(disposition)
+			if err != nil {
+				t.Fatalf("invalid Content-Disposition %q: %v", disposition, err)
+			}
+			decoded.FileName = dispositionParams["filename"]
+		}
+		parts = append(parts, decoded)
+	}
+}
+
+func TestWriteMessageRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	binary := make([]byte, 10000)
+	for i := range binary {
+		binary[i] = byte(i * 7)
+	}
+	binaryPath := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(binaryPath, binary, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unicodeName := "Übersicht-रिपोर्ट.txt"
+	unicodePath := filepath.Join(dir, unicodeName)
+	unicodeContent := []byte("a=b\r\n=3D literally\r\n")
+	if err := os.WriteFile(unicodePath, unicodeContent, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	longLine := strings.Repeat("0123456789 ", 30) + strings.Repeat("x", 200)
+	textBody := "Totals: a=1, b==2, =?utf-8?q?not-a-word?=\r\n" + longLine + "\r\nGrüße, नमस्ते\r\ntrailing space \r\n"
+	htmlBody := `<p style="color:red">Sum = 3</p>` + strings.Repeat("<b>long</b>", 40)
+
+	tests := []struct {
+		name  string
+		msg   *Message
+		parts []decodedPart
+	}{
+		{
+			name: "text",
+			msg:  &Message{TextBody: textBody},
+			parts: []decodedPart{
+				{ContentType: "text/plain", Body: []byte(textBody)},
+			},
+		},
+		{
+			name: "text and HTML",
+			msg:  &Message{TextBody: textBody, HTMLBody: htmlBody},
+			parts: []decodedPart{
+				{ContentType: "text/plain", Body: []byte(textBody)},
+				{ContentType: "text/html", Body: []byte(htmlBody)},
+			},
+		},
+		{
+			name: "attachments",
+			msg:  &Message{TextBody: "See attached.", Attachments: []string{binaryPath, unicodePath}},
+			parts: []decodedPart{
+				{ContentType: "text/plain", Body: []byte("See attached.")},
+				{ContentType: "application/octet-stream", FileName: "data.bin", Body: binary},
+				{ContentType: "text/plain", FileName: unicodeName, Body: unicodeContent},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret")
+			tt.msg.Recipients = Recipients{To: []string{"rcpt@example.com"}}
+			tt.msg.Subject = "Round trip"
+
+			var out bytes.Buffer
+			if err := service.WriteMessage(&out, tt.msg); err != nil {
+				t.Fatalf("WriteMessage() error = %v", err)
+			}
+			_, parts := parseMessage(t, out.Bytes())
+			if len(parts) != len(tt.parts) {
+				t.Fatalf("message has %d parts, want %d", len(parts), len(tt.parts))
+			}
+			for i, want := range tt.parts {
+				got := parts[i]
+				if got.ContentType != want.ContentType {
+					t.Errorf("part %d Content-Type = %q, want %q", i, got.ContentType, want.ContentType)
+				}
+				if got.FileName != want.FileName {
+					t.Errorf("part %d filename = %q, want %q", i, got.FileName, want.FileName)
+				}
+				if !bytes.Equal(got.Body, want.Body) {
+					t.Errorf("part %d body does not round-trip:\n got %q\nwant %q", i, got.Body, want.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMessageLeavesMessageIDUnset(t *testing.T) {
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret")
+	msg := &Message{Recipients: Recipients{To: []string{"rcpt@example.com"}}, Subject: "IDs", TextBody: "Hello"}
+
+	const sends = 8
+	ids := make(chan string, sends)
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out bytes.Buffer
+			if err := service.WriteMessage(&out, msg); err != nil {
+				t.Errorf("WriteMessage() error = %v", err)
+				return
+			}
+			parsed, err := mail.ReadMessage(&out)
+			if err != nil {
+				t.Errorf("net/mail cannot parse the message: %v", err)
+				return
+			}
+			ids <- parsed.Header.Get("Message-ID")
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool)
+	for id := range ids {
+		if id == "" || seen[id] {
+			t.Errorf("Message-ID %q is missing or reused", id)
+		}
+		seen[id] = true
+	}
+	if msg.MessageID != "" {
+		t.Errorf("WriteMessage() set msg.MessageID to %q", msg.MessageID)
+	}
+}
+
+// BenchmarkWriteMessage streams messages with growing attachments. Memory per
+// operation stays flat because attachments are read as they are written.
+func BenchmarkWriteMessage(b *testing.B) {
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret")
+	for _, size := range []int64{1 << 20, 16 << 20, 64 << 20} {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "attachment.bin")
+			file, err := os.Create(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.CopyN(file, rand.Reader, size); err != nil {
+				b.Fatal(err)
+			}
+			if err := file.Close(); err != nil {
+				b.Fatal(err)
+			}
+			msg := &Message{
+				Recipients:  Recipients{To: []string{"rcpt@example.com"}},
+				Subject:     "Benchmark",
+				TextBody:    "See attached.",
+				Attachments: []string{path},
+			}
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := service.WriteMessage(io.Discard, msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/base64"
+	"io"
+)
+
+// maxEncodedLineLength is the longest line allowed in base64 encoded content (RFC 2045).
+const maxEncodedLineLength = 76
+
+// crlf ends encoded lines. Writing it as a shared byte slice rather than a
+// string avoids an allocation per line for writers without WriteString.
+var crlf = []byte("\r\n")
+
+// lineWrapWriter inserts a CRLF after every maxEncodedLineLength bytes written to it.
+type lineWrapWriter struct {
+	w       io.Writer
+	lineLen int
+}
+
+func (lw *lineWrapWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := maxEncodedLineLength - lw.lineLen
+		if chunk > len(p) {
+			chunk = len(p)
+		}
+		n, err := lw.w.Write(p[:chunk])
+		written += n
+		lw.lineLen += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+
+		if lw.lineLen == maxEncodedLineLength {
+			if _, err := lw.w.Write(crlf); err != nil {
+				return written, err
+			}
+			lw.lineLen = 0
+		}
+	}
+	return written, nil
+}
+
+// writeBase64 streams src into w as base64 wrapped at 76 columns,
+// terminating the final line with CRLF.
+// Parameters:
+// - w: The destination, typically a MIME part.
+// - src: The raw content to encode.
+func writeBase64(w io.Writer, src io.Reader) error {
+	wrapper := &lineWrapWriter{w: w}
+	encoder := base64.NewEncoder(base64.StdEncoding, wrapper)
+	if _, err := io.Copy(encoder, src); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	if wrapper.lineLen > 0 {
+		_, err := w.Write(crlf)
+		return err
+	}
+	return nil
+}
+
+// base64Length returns the number of bytes writeBase64 produces for n bytes
+// of content, including the line breaks.
+// Parameters:
+// - n: The size of the raw content.
+func base64Length(n int64) int64 {
+	encoded := (n + 2) / 3 * 4
+	return encoded + (encoded+maxEncodedLineLength-1)/maxEncodedLineLength*2
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// Sentinel errors describing why sending failed. Every *SMTPError matches
+// exactly one of the SMTP related ones with errors.Is, for example:
+//
+//	if errors.Is(err, services.ErrAuth) { ... }
+var (
+	// ErrConnection means the SMTP server could not be reached or closed the session.
+	ErrConnection = errors.New("SMTP connection failed")
+	// ErrTLS means the TLS handshake or STARTTLS negotiation failed.
+	ErrTLS = errors.New("SMTP TLS negotiation failed")
+	// ErrAuth means the server rejected the credentials or offered no usable mechanism.
+	ErrAuth = errors.New("SMTP authentication failed")
+	// ErrSenderRejected means the server refused the envelope sender (MAIL FROM).
+	ErrSenderRejected = errors.New("SMTP server rejected sender")
+	// ErrRecipientRejected means the server refused a recipient (RCPT TO).
+	ErrRecipientRejected = errors.New("SMTP server rejected recipient")
+	// ErrMessageTooLarge means the message exceeds the server's size limit.
+	ErrMessageTooLarge = errors.New("message too large")
+	// ErrMessageRejected means the server refused the message content.
+	ErrMessageRejected = errors.New("SMTP server rejected message")
+	// ErrAttachmentUnreadable means an attachment or inline resource could not be read.
+	ErrAttachmentUnreadable = errors.New("attachment unreadable")
+	// ErrInvalidHeader means a header-bound value (address, custom header, Message-ID)
+	// contains characters that could inject additional header lines.
+	ErrInvalidHeader = errors.New("invalid header value")
+	// ErrAPI means an email provider's HTTP API refused the request.
+	ErrAPI = errors.New("email API request failed")
+	// ErrTimeout means connecting to the SMTP server or waiting for it to respond
+	// exceeded the configured dial or I/O timeout.
+	ErrTimeout = errors.New("SMTP operation timed out")
+	// ErrServiceClosed means the message was sent after Close ended the pooled SMTP sessions.
+	ErrServiceClosed = errors.New("email service is closed")
+)
+
+// enhancedCodeRe matches an RFC 3463 enhanced status code at the start of a reply, e.g. "5.1.1".
+var enhancedCodeRe = regexp.MustCompile(`^([245]\.\d{1,3}\.\d{1,3})\s+`)
+
+// SMTPError is a failure that happened while talking to the SMTP server.
+// Use errors.Is with the sentinel errors to classify it, and errors.As to read
+// the SMTP reply code and enhanced status code when the server supplied them.
+type SMTPError struct {
+	Kind         error  // One of the sentinel errors above.
+	Recipient    string // The rejected address, for ErrRecipientRejected.
+	Code         int    // The SMTP reply code, e.g. 550; zero when the server sent none.
+	EnhancedCode string // The enhanced status code, e.g. "5.1.1"; empty when not supplied.
+	Message      string // The server's reply text without the enhanced status code.
+	Err          error  // The underlying error.
+}
+
+// newSMTPError classifies err and extracts the SMTP reply details from it.
+// Parameters:
+// - kind: The sentinel error describing the failed step.
+// - err: The underlying error, typically a *textproto.Error or a network error.
+func newSMTPError(kind, err error) *SMTPError {
+	e := &SMTPError{Kind: kind, Err: err}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		e.Code = protoErr.Code
+		e.Message = protoErr.Msg
+		if match := enhancedCodeRe.FindStringSubmatch(protoErr.Msg); match != nil {
+			e.EnhancedCode = match[1]
+			e.Message = strings.TrimSpace(protoErr.Msg[len(match[0]):])
+		}
+	}
+
+	// 552 and 5.3.4 report an exceeded size limit whichever command they answer.
+	if kind != ErrRecipientRejected && (e.Code == 552 || e.EnhancedCode == "5.3.4") {
+		e.Kind = ErrMessageTooLarge
+	}
+	return e
+}
+
+// newRecipientError returns the error for a recipient the server refused.
+// Parameters:
+// - recipient: The rejected address.
+// - err: The error returned for the RCPT TO command.
+func newRecipientError(recipient string, err error) *SMTPError {
+	e := newSMTPError(ErrRecipientRejected, err)
+	e.Recipient = recipient
+	return e
+}
+
+func (e *SMTPError) Error() string {
+	msg := e.Kind.Error()
+	if e.Recipient != "" {
+		msg += " " + e.Recipient
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *SMTPError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error describing this failure.
+func (e *SMTPError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Temporary reports whether the server signalled a transient (4xx) failure.
+func (e *SMTPError) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// attachmentError returns the error for an attachment or inline resource that could not be read.
+// Parameters:
+// - path: The file path.
+// - err: The error returned while reading the file.
+func attachmentError(path string, err error) error {
+	return fmt.Errorf("%w: %s: %w", ErrAttachmentUnreadable, path, err)
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMessage is a message accepted by fakeSMTP.
+type fakeMessage struct {
+	Mail string   // The MAIL FROM command line.
+	Rcpt []string // The RCPT TO command lines.
+	Data string   // The message as uploaded, without the terminating dot.
+	TLS  bool     // Whether the session was encrypted when the message was sent.
+}
+
+// fakeAuth is an AUTH exchange completed with fakeSMTP.
+type fakeAuth struct {
+	Mechanism string   // The SASL mechanism named in the AUTH command.
+	Responses []string // The client's decoded responses, initial response first.
+}
+
+// fakeSMTP is an in-process SMTP server for tests. It speaks just enough
+// ESMTP for the transport: EHLO, STARTTLS, AUTH (PLAIN, LOGIN, CRAM-MD5 and
+// XOAUTH2), MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+type fakeSMTP struct {
+	tlsConfig  *tls.Config // Server certificate for implicit TLS and STARTTLS.
+	implicit   bool        // Wrap connections in TLS before the greeting.
+	startTLS   bool        // Advertise STARTTLS on plain connections.
+	auth       []string    // AUTH mechanisms to advertise; any credentials are accepted.
+	extensions []string    // Extra EHLO keywords, e.g. "SIZE 1000".
+
+	// replies, when set, may override the reply to MAIL, RCPT, DATA (the
+	// reply after the message), RSET and NOOP: it receives the command line
+	// and returns the reply, or "" for the default one. It may block to
+	// simulate a server that stops responding. A 421 reply closes the connection.
+	replies func(command string) string
+
+	mu       sync.Mutex
+	commands []string
+	messages []fakeMessage
+	auths    []fakeAuth
+}
+
+// newTestCertificate creates a self-signed certificate for 127.0.0.1 and
+// localhost.
+// Returns the server configuration and a pool trusting the certificate.
+func newTestCertificate(t testing.TB) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+// start listens on a free local port and serves connections until the test ends.
+// Returns the host and port to connect to.
+func (f *fakeSMTP) start(t testing.TB) (string, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.implicit {
+		listener = tls.NewListener(listener, f.tlsConfig)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port
+}
+
+// Messages returns the messages accepted so far.
+func (f *fakeSMTP) Messages() []fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeMessage(nil), f.messages...)
+}
+
+// Auths returns the AUTH exchanges completed so far.
+func (f *fakeSMTP) Auths() []fakeAuth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeAuth(nil), f.auths...)
+}
+
+// Commands returns the command lines received so far.
+func (f *fakeSMTP) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	encrypted := f.implicit
+	reader := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+	}
+
+	// answer sends the reply to a command, unless f.replies overrides it.
+	// Returns whether the reply was positive.
+	closing := false
+	answer := func(command, fallback string) bool {
+		response := fallback
+		if f.replies != nil {
+			if override := f.replies(command); override != "" {
+				response = override
+			}
+		}
+		reply(response)
+		closing = strings.HasPrefix(response, "421")
+		return strings.HasPrefix(response, "2")
+	}
+
+	reply("220 fake ESMTP ready")
+	var current fakeMessage
+	for !closing {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		f.mu.Lock()
+		f.commands = append(f.commands, line)
+		f.mu.Unlock()
+
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			lines := []string{"250-fake"}
+			if f.startTLS && !encrypted {
+				lines = append(lines, "250-STARTTLS")
+			}
+			if len(f.auth) > 0 {
+				lines = append(lines, "250-AUTH "+strings.Join(f.auth, " "))
+			}
+			for _, extension := range f.extensions {
+				lines = append(lines, "250-"+extension)
+			}
+			reply(append(lines, "250 HELP")...)
+		case "STARTTLS":
+			if !f.startTLS || encrypted {
+				reply("502 not available")
+				continue
+			}
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, f.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, reader, encrypted = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				reply("501 syntax error")
+				continue
+			}
+			exchange := fakeAuth{Mechanism: strings.ToUpper(fields[1])}
+			respond := func(encoded string) bool {
+				decoded, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					reply("501 invalid base64")
+					return false
+				}
+				exchange.Responses = append(exchange.Responses, string(decoded))
+				return true
+			}
+			challenge := func(prompt string) bool {
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte(prompt)))
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return false
+				}
+				return respond(strings.TrimRight(line, "\r\n"))
+			}
+			ok := true
+			switch exchange.Mechanism {
+			case "PLAIN", "XOAUTH2":
+				if len(fields) > 2 {
+					ok = respond(fields[2])
+				} else {
+					ok = challenge("")
+				}
+			case "LOGIN":
+				if len(fields) > 2 {
+					ok = respond(fields[2])
+				} else {
+					ok = challenge("Username:")
+				}
+				ok = ok && challenge("Password:")
+			case "CRAM-MD5":
+				ok = challenge("<1896.697170952@fake>")
+			default:
+				reply("504 unrecognized authentication type")
+				continue
+			}
+			if !ok {
+				continue
+			}
+			f.mu.Lock()
+			f.auths = append(f.auths, exchange)
+			f.mu.Unlock()
+			reply("235 authenticated")
+		case "MAIL":
+			current = fakeMessage{}
+			if answer(line, "250 ok") {
+				current = fakeMessage{Mail: line, TLS: encrypted}
+			}
+		case "RCPT":
+			if answer(line, "250 ok") {
+				current.Rcpt = append(current.Rcpt, line)
+			}
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			current.Data = data.String()
+			if answer(line, "250 queued") {
+				f.mu.Lock()
+				f.messages = append(f.messages, current)
+				f.mu.Unlock()
+			}
+		case "RSET":
+			current = fakeMessage{}
+			answer(line, "250 ok")
+		case "NOOP":
+			answer(line, "250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown command")
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileTransport writes every message to a directory instead of sending it,
+// either as individual .eml files or as a Maildir.
+type FileTransport struct {
+	dir     string
+	maildir bool
+}
+
+// NewFileTransport creates a transport that writes each message to its own .eml file.
+// Parameters:
+// - dir: The directory to write to; it is created when missing.
+func NewFileTransport(dir string) *FileTransport {
+	return &FileTransport{dir: dir}
+}
+
+// NewMaildirTransport creates a transport that delivers into a Maildir,
+// writing to tmp/ and moving finished messages to new/ as mail clients expect.
+// Parameters:
+// - dir: The Maildir root; the cur, new and tmp subdirectories are created when missing.
+func NewMaildirTransport(dir string) *FileTransport {
+	return &FileTransport{dir: dir, maildir: true}
+}
+
+// Deliver writes the message. The envelope is not recorded, so Bcc recipients
+// do not appear in the output.
+// Parameters:
+// - ctx: Checked before writing.
+// - from: The envelope sender (unused).
+// - to: The envelope recipients (unused).
+// - message: The complete message with CRLF line endings.
+func (t *FileTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	return t.DeliverStream(ctx, from, to, func(w io.Writer) error {
+		_, err := io.WriteString(w, message)
+		return err
+	})
+}
+
+// DeliverStream writes the message to its file as it is built.
+// Parameters:
+// - ctx: Checked before writing.
+// - from: The envelope sender (unused).
+// - to: The envelope recipients (unused).
+// - write: Writes the complete message.
+func (t *FileTransport) DeliverStream(ctx context.Context, from string, to []string, write func(io.Writer) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	name, err := uniqueFileName()
+	if err != nil {
+		return err
+	}
+
+	if !t.maildir {
+		if err := os.MkdirAll(t.dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %v", err)
+		}
+		path := filepath.Join(t.dir, name+".eml")
+		return writeFileAtomic(path+".tmp", path, 0o644, write)
+	}
+
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(t.dir, sub), 0o700); err != nil {
+			return fmt.Errorf("failed to create Maildir: %v", err)
+		}
+	}
+	// Maildir readers only look at new/, so messages are written in tmp/ and moved once complete.
+	return writeFileAtomic(filepath.Join(t.dir, "tmp", name), filepath.Join(t.dir, "new", name), 0o600, write)
+}
+
+// uniqueFileName returns a name that sorts by delivery time and never
+// collides, in the "time.unique.host" form Maildir requires.
+func uniqueFileName() (string, error) {
+	random := make([]byte, 6)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate file name: %v", err)
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	now := time.Now()
+	return fmt.Sprintf("%d.%09d_%s.%s", now.Unix(), now.Nanosecond(), hex.EncodeToString(random), maildirHost(host)), nil
+}
+
+// maildirHost escapes the characters a Maildir file name may not contain in
+// its host part: "/" separates directories and ":" starts the info suffix.
+// Parameters:
+// - host: The machine's host name.
+func maildirHost(host string) string {
+	return strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+}
+
+// writeFileAtomic writes a file under a temporary name and renames it once
+// complete, so readers never see a partial message.
+// Parameters:
+// - tmpPath: The temporary name, on the same file system as path.
+// - path: The final name.
+// - perm: The file permissions.
+// - write: Writes the content.
+func writeFileAtomic(tmpPath, path string, perm os.FileMode, write func(io.Writer) error) error {
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	buffered := bufio.NewWriter(file)
+	out := &errorWriter{w: buffered}
+	if err := write(out); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		if out.err != nil {
+			return fmt.Errorf("failed to write message: %v", out.err)
+		}
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+const testTransportMessage = "From: sender@example.com\r\nSubject: Transport test\r\n\r\nline one\r\nline two\r\n"
+
+// dirEntries returns the names of the files in a directory.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	transport := NewFileTransport(dir)
+	for i := 0; i < 2; i++ {
+		if err := transport.Deliver(context.Background(), "sender@example.com", []string{"rcpt@example.com"}, testTransportMessage); err != nil {
+			t.Fatalf("Deliver() error = %v", err)
+		}
+	}
+
+	names := dirEntries(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("output directory holds %q, want two messages", names)
+	}
+	for _, name := range names {
+		if filepath.Ext(name) != ".eml" {
+			t.Errorf("file %s is not an .eml file", name)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != testTransportMessage {
+			t.Errorf("%s holds %q, want the message unchanged", name, data)
+		}
+	}
+}
+
+func TestMaildirTransport(t *testing.T) {
+	dir := t.TempDir()
+	transport := NewMaildirTransport(dir)
+
+	// While the message is written it sits in tmp/, invisible to readers of new/.
+	err := transport.DeliverStream(context.Background(), "sender@example.com", []string{"rcpt@example.com"}, func(w io.Writer) error {
+		if _, err := io.WriteString(w, testTransportMessage[:20]); err != nil {
+			return err
+		}
+		if tmp := dirEntries(t, filepath.Join(dir, "tmp")); len(tmp) != 1 {
+			t.Errorf("tmp/ holds %q while writing, want the message", tmp)
+		}
+		if pending := dirEntries(t, filepath.Join(dir, "new")); len(pending) != 0 {
+			t.Errorf("new/ holds %q before the message is complete", pending)
+		}
+		_, err := io.WriteString(w, testTransportMessage[20:])
+		return err
+	})
+	if err != nil {
+		t.Fatalf("DeliverStream() error = %v", err)
+	}
+
+	if tmp := dirEntries(t, filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("tmp/ still holds %q", tmp)
+	}
+	if cur := dirEntries(t, filepath.Join(dir, "cur")); len(cur) != 0 {
+		t.Errorf("cur/ holds %q, want it empty", cur)
+	}
+	delivered := dirEntries(t, filepath.Join(dir, "new"))
+	if len(delivered) != 1 {
+		t.Fatalf("new/ holds %q, want one message", delivered)
+	}
+	if !regexp.MustCompile(`^\d+\.\d{9}_[0-9a-f]{12}\.[^/:]+$`).MatchString(delivered[0]) {
+		t.Errorf("file name %q is not in the time.unique.host form", delivered[0])
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "new", delivered[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testTransportMessage {
+		t.Errorf("delivered message is %q, want it unchanged", data)
+	}
+
+	// A message that fails to build leaves nothing behind.
+	buildErr := errors.New("attachment vanished")
+	err = transport.DeliverStream(context.Background(), "sender@example.com", []string{"rcpt@example.com"}, func(w io.Writer) error {
+		io.WriteString(w, testTransportMessage[:20])
+		return buildErr
+	})
+	if !errors.Is(err, buildErr) {
+		t.Fatalf("DeliverStream() error = %v, want %v", err, buildErr)
+	}
+	if tmp := dirEntries(t, filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("tmp/ holds the partial message %q", tmp)
+	}
+	if delivered := dirEntries(t, filepath.Join(dir, "new")); len(delivered) != 1 {
+		t.Errorf("new/ holds %q, want only the first message", delivered)
+	}
+}
+
+func TestMaildirHost(t *testing.T) {
+	tests := map[string]string{
+		"mail.example.com": "mail.example.com",
+		"host/with/slash":  `host\057with\057slash`,
+		"host:2":           `host\0722`,
+	}
+	for host, want := range tests {
+		if got := maildirHost(host); got != want {
+			t.Errorf("maildirHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
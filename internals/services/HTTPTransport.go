@@ -0,0 +1,128 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAPITimeout bounds a whole request to an email provider's HTTP API.
+const DefaultAPITimeout = 60 * time.Second
+
+// maxAPIErrorBody limits how much of an error response is read and reported.
+const maxAPIErrorBody = 4096
+
+// APIError is a request an email provider's HTTP API answered with an error
+// status. It matches ErrAPI with errors.Is, and also ErrAuth for rejected
+// credentials or ErrMessageTooLarge for oversized messages.
+type APIError struct {
+	Kind       error  // ErrAPI, ErrAuth or ErrMessageTooLarge.
+	Provider   string // The transport name, e.g. "sendgrid".
+	StatusCode int    // The HTTP status code.
+	Message    string // The provider's error message, or the response body.
+}
+
+// newAPIError classifies an error response.
+// Parameters:
+// - provider: The transport name.
+// - resp: The response with a non-2xx status.
+func newAPIError(provider string, resp *http.Response) *APIError {
+	e := &APIError{Kind: ErrAPI, Provider: provider, StatusCode: resp.StatusCode}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		e.Kind = ErrAuth
+	case http.StatusRequestEntityTooLarge:
+		e.Kind = ErrMessageTooLarge
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxAPIErrorBody))
+	e.Message = apiErrorMessage(body)
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Is reports whether target is the sentinel error describing this failure.
+func (e *APIError) Is(target error) bool {
+	return target == e.Kind || target == ErrAPI
+}
+
+// Temporary reports whether the provider is rate limiting or failing
+// internally, so the request may succeed later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// apiErrorMessage extracts the error message from a provider's JSON error
+// body, falling back to the trimmed body when it has no recognised shape.
+// Parameters:
+// - body: The response body.
+func apiErrorMessage(body []byte) string {
+	var parsed struct {
+		Message string `json:"message"` // Mailgun and SES; Postmark uses "Message", matched case-insensitively.
+		Errors  []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"errors"` // SendGrid
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		if len(parsed.Errors) > 0 {
+			messages := make([]string, 0, len(parsed.Errors))
+			for _, e := range parsed.Errors {
+				if e.Field != "" {
+					messages = append(messages, e.Field+": "+e.Message)
+				} else {
+					messages = append(messages, e.Message)
+				}
+			}
+			return strings.Join(messages, "; ")
+		}
+		if parsed.Message != "" {
+			return parsed.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// doAPIRequest sends a request to a provider's API and turns error statuses into an *APIError.
+// Parameters:
+// - client: The HTTP client.
+// - provider: The transport name, used in errors.
+// - req: The request, carrying the delivery's context.
+func doAPIRequest(client *http.Client, provider string, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(provider, resp)
+	}
+	// Drain the body so the connection can be reused.
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// newAPIClient returns the HTTP client used by the provider transports.
+func newAPIClient() *http.Client {
+	return &http.Client{Timeout: DefaultAPITimeout}
+}
+
+// apiEndpoint returns the configured base URL without a trailing slash, or the provider's default.
+// Parameters:
+// - endpoint: The configured base URL; may be empty.
+// - fallback: The provider's public API base URL.
+func apiEndpoint(endpoint, fallback string) string {
+	if endpoint == "" {
+		return fallback
+	}
+	return strings.TrimRight(endpoint, "/")
+}
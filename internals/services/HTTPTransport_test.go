@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// apiRequest is a request received by an apiStandIn.
+type apiRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// apiStandIn is a local stand-in for a provider's HTTP API that records the
+// requests it receives and answers them with a fixed status and body.
+type apiStandIn struct {
+	URL string
+
+	mu       sync.Mutex
+	requests []apiRequest
+}
+
+// newAPIStandIn starts a stand-in that answers every request with status and body.
+func newAPIStandIn(t *testing.T, status int, body string) *apiStandIn {
+	t.Helper()
+	standIn := &apiStandIn{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		header := r.Header.Clone()
+		header.Set("Host", r.Host)
+		standIn.mu.Lock()
+		standIn.requests = append(standIn.requests, apiRequest{Method: r.Method, Path: r.URL.EscapedPath(), Header: header, Body: data})
+		standIn.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	standIn.URL = server.URL
+	return standIn
+}
+
+// request returns the only request received, failing the test if there was not exactly one.
+func (s *apiStandIn) request(t *testing.T) apiRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) != 1 {
+		t.Fatalf("API received %d requests, want 1", len(s.requests))
+	}
+	return s.requests[0]
+}
+
+// testInlineImage is the content of the inline image in composeAPITestMessage.
+var testInlineImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR fake image data")
+
+// composeAPITestMessage composes a message with every feature the providers
+// map: display names, Cc, Bcc, Reply-To, a custom header, text and HTML
+// bodies, an attachment and an inline image.
+// Returns the envelope recipients and the composed message.
+func composeAPITestMessage(t *testing.T) ([]string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.csv")
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(report, []byte("a,b\r\n1,2\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logo, testInlineImage, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &Message{
+		Recipients: Recipients{
+			To:      []string{"Alice <alice@example.com>"},
+			Cc:      []string{"carol@example.com"},
+			Bcc:     []string{"hidden@example.com"},
+			ReplyTo: []string{"replies@example.com"},
+		},
+		Subject:     "Quarterly report",
+		TextBody:    "See the report.",
+		HTMLBody:    `<p>See the report.</p><img src="cid:logo@example.com">`,
+		Attachments: []string{report},
+		Inline:      []InlineResource{{ContentID: "logo@example.com", Path: logo}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+	}
+	service := NewDhanuEmailService("localhost", "25", "reports@example.com", "secret", WithFromName("Reports"))
+	data, err := service.Compose(msg)
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	return msg.Envelope(), data
+}
+
+// decodeBase64 decodes standard base64, failing the test on invalid input.
+func decodeBase64(t *testing.T, encoded string) string {
+	t.Helper()
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("invalid base64 %q: %v", encoded, err)
+	}
+	return string(decoded)
+}
+
+func TestAPIErrorClassification(t *testing.T) {
+	tests := []struct {
+		status        int
+		body          string
+		wantKind      error
+		wantTemporary bool
+		wantMessage   string
+	}{
+		{status: http.StatusUnauthorized, body: `{"errors":[{"message":"bad key"}]}`, wantKind: ErrAuth, wantMessage: "bad key"},
+		{status: http.StatusRequestEntityTooLarge, body: `{"message":"too big"}`, wantKind: ErrMessageTooLarge, wantMessage: "too big"},
+		{status: http.StatusTooManyRequests, body: `{"Message":"slow down"}`, wantKind: ErrAPI, wantTemporary: true, wantMessage: "slow down"},
+		{status: http.StatusBadGateway, body: "upstream failed", wantKind: ErrAPI, wantTemporary: true, wantMessage: "upstream failed"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			standIn := newAPIStandIn(t, tt.status, tt.body)
+			err := NewSendGridTransport("key", standIn.URL).Deliver(context.Background(), "reports@example.com",
+				[]string{"alice@example.com"}, "From: reports@example.com\r\nTo: alice@example.com\r\nSubject: x\r\nContent-Type: text/plain\r\n\r\nHello\r\n")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Deliver() error = %v, want an *APIError", err)
+			}
+			if !errors.Is(err, tt.wantKind) || !errors.Is(err, ErrAPI) {
+				t.Errorf("error %v does not match %v and ErrAPI", err, tt.wantKind)
+			}
+			if apiErr.Temporary() != tt.wantTemporary || isTransient(err) != tt.wantTemporary {
+				t.Errorf("Temporary() = %v, want %v", apiErr.Temporary(), tt.wantTemporary)
+			}
+			if apiErr.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", apiErr.Message, tt.wantMessage)
+			}
+		})
+	}
+}
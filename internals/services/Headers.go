@@ -0,0 +1,205 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// mailerName is written to the X-Mailer header of every message.
+const mailerName = "Dhanu"
+
+// reservedHeaders are generated from dedicated Message fields or describe the
+// MIME structure, so they cannot be set as custom headers.
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// maxHeaderLineLength is the recommended maximum header line length (RFC 5322 section 2.1.1).
+const maxHeaderLineLength = 78
+
+// formatHeader renders a header field, folding the value at whitespace so lines
+// stay within 78 characters wherever the value allows it (RFC 5322 section 2.2.3).
+// Parameters:
+// - name: The header field name.
+// - value: The header value, already encoded for transport.
+func formatHeader(name, value string) string {
+	var b strings.Builder
+	line := name + ":"
+	for _, word := range strings.Split(value, " ") {
+		chunk := " " + word
+		// Fold before the whitespace, unless the line holds nothing but the field name yet.
+		if len(line)+len(chunk) > maxHeaderLineLength && len(line) > len(name)+1 {
+			b.WriteString(line)
+			b.WriteString("\r\n")
+			line = chunk
+			continue
+		}
+		line += chunk
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// maxEncodedWordLength bounds each RFC 2047 encoded-word so that a folded
+// header line, including a short field name, stays within 78 characters.
+const maxEncodedWordLength = 60
+
+// encodeHeaderText encodes unstructured header text such as the subject as
+// RFC 2047 encoded-words when it contains non-ASCII characters, or text that
+// a reader would take for an encoded-word ("=?"), which RFC 2047 section 5
+// forbids in plain text.
+// Mostly non-ASCII text (e.g. Devanagari) uses the shorter B encoding,
+// mostly ASCII text (e.g. German) stays readable with Q encoding.
+// The encoded-words are separated by spaces so formatHeader can fold between them.
+// Parameters:
+// - text: The header text to encode.
+func encodeHeaderText(text string) string {
+	nonASCII := 0
+	for _, r := range text {
+		if r >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+	if nonASCII == 0 && !strings.Contains(text, "=?") {
+		return text
+	}
+
+	encoding := byte('Q')
+	if nonASCII*3 > utf8.RuneCountInString(text) {
+		encoding = 'B'
+	}
+
+	// Split on rune boundaries so no character is divided between two encoded-words.
+	var words []string
+	chunk := ""
+	for _, r := range text {
+		candidate := chunk + string(r)
+		if chunk != "" && len(encodeWord(encoding, candidate)) > maxEncodedWordLength {
+			words = append(words, encodeWord(encoding, chunk))
+			candidate = string(r)
+		}
+		chunk = candidate
+	}
+	words = append(words, encodeWord(encoding, chunk))
+	return strings.Join(words, " ")
+}
+
+// encodeWord renders text as a single RFC 2047 encoded-word.
+// Parameters:
+// - encoding: 'B' for base64 or 'Q' for the quoted-printable-like Q encoding.
+// - text: The UTF-8 text to encode.
+func encodeWord(encoding byte, text string) string {
+	if encoding == 'B' {
+		return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(text)) + "?="
+	}
+
+	var b strings.Builder
+	b.WriteString("=?UTF-8?Q?")
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == ' ':
+			b.WriteByte('_')
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '!', c == '*', c == '+', c == '-', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "=%02X", c)
+		}
+	}
+	b.WriteString("?=")
+	return b.String()
+}
+
+// formatAddress parses an address such as "Name <user@example.com>" and renders
+// it with the display name encoded per RFC 2047 when required.
+// Parameters:
+// - address: The address to format.
+func formatAddress(address string) (string, error) {
+	if err := checkAddress(address); err != nil {
+		return "", err
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	return parsed.String(), nil
+}
+
+// formatAddressList formats a list of addresses as a comma-separated header value.
+// Parameters:
+// - addresses: The addresses to format.
+func formatAddressList(addresses []string) (string, error) {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		value, err := formatAddress(address)
+		if err != nil {
+			return "", err
+		}
+		formatted = append(formatted, value)
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// envelopeAddress returns the bare address for the SMTP envelope, stripping any display name.
+// Parameters:
+// - address: The address, optionally in "Name <user@example.com>" form.
+func envelopeAddress(address string) string {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return parsed.Address
+}
+
+// hasHeader reports whether headers contain a field with the given name, ignoring case.
+// Parameters:
+// - headers: The custom headers of a message.
+// - name: The field name to look for.
+func hasHeader(headers []Header, name string) bool {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMessageID generates a globally unique Message-ID (without angle brackets).
+// Parameters:
+// - domain: The domain part of the ID, normally the sender's domain.
+func newMessageID(domain string) (string, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate Message-ID: %v", err)
+	}
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(random), domain), nil
+}
+
+// messageIDDomain returns the domain used for generated Message-IDs: the
+// configured domain, else the domain of the sender's address.
+func (es *DhanuEmailService) messageIDDomain() string {
+	if es.idDomain != "" {
+		return es.idDomain
+	}
+	if at := strings.LastIndex(es.fromEmail, "@"); at >= 0 && at < len(es.fromEmail)-1 {
+		return es.fromEmail[at+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"mime"
+	"strings"
+	"testing"
+)
+
+func TestEncodeHeaderText(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantEncoded bool
+	}{
+		{name: "ASCII", text: "Nightly build report", wantEncoded: false},
+		{name: "German", text: "Grüße aus München", wantEncoded: true},
+		{name: "Hindi", text: "नमस्ते दुनिया", wantEncoded: true},
+		{name: "encoded-word lookalike", text: "Hi =?utf-8?q?x?=", wantEncoded: true},
+		{name: "bare marker", text: "a=?b", wantEncoded: true},
+		{name: "long", text: strings.Repeat("Übersicht ", 20), wantEncoded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := encodeHeaderText(tt.text)
+			if encoded == tt.text && tt.wantEncoded {
+				t.Fatalf("encodeHeaderText(%q) was not encoded", tt.text)
+			}
+			if encoded != tt.text && !tt.wantEncoded {
+				t.Fatalf("encodeHeaderText(%q) = %q, want it unchanged", tt.text, encoded)
+			}
+			decoded, err := new(mime.WordDecoder).DecodeHeader(encoded)
+			if err != nil {
+				t.Fatalf("cannot decode %q: %v", encoded, err)
+			}
+			if decoded != tt.text {
+				t.Errorf("decoded %q, want %q", decoded, tt.text)
+			}
+		})
+	}
+}
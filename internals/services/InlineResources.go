@@ -0,0 +1,140 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// InlineResource is a file embedded in an HTML email and referenced from the
+// HTML through a "cid:" URL, e.g. <img src="cid:chart.png.1a2b@dhanu">.
+type InlineResource struct {
+	ContentID string // The Content-ID without angle brackets.
+	Path      string // Path of the file to embed.
+}
+
+// imgSrcRe matches the quoted src attribute of an <img> tag.
+var imgSrcRe = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*)(["'])([^"']*)(["'])`)
+
+// EmbedLocalImages rewrites <img> tags that reference local files into "cid:"
+// references and returns the inline resources that must accompany the HTML.
+// Remote (http, https), data and existing cid URLs are left untouched.
+// Parameters:
+// - htmlBody: The HTML content.
+// - baseDir: The directory relative image paths are resolved against.
+// Returns the rewritten HTML and the inline resources to embed.
+func EmbedLocalImages(htmlBody, baseDir string) (string, []InlineResource, error) {
+	var resources []InlineResource
+	contentIDs := make(map[string]string)
+	var embedErr error
+
+	rewritten := imgSrcRe.ReplaceAllStringFunc(htmlBody, func(tag string) string {
+		match := imgSrcRe.FindStringSubmatch(tag)
+		prefix, quote, src := match[1], match[2], match[3]
+		if embedErr != nil || !isLocalReference(src) {
+			return tag
+		}
+
+		path := strings.TrimPrefix(src, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		path = filepath.Clean(path)
+
+		// Reuse the Content-ID when the same image is referenced more than once.
+		contentID, ok := contentIDs[path]
+		if !ok {
+			if _, err := os.Stat(path); err != nil {
+				embedErr = fmt.Errorf("inline image %s: %v", src, err)
+				return tag
+			}
+			contentID, embedErr = newContentID(filepath.Base(path))
+			if embedErr != nil {
+				return tag
+			}
+			contentIDs[path] = contentID
+			resources = append(resources, InlineResource{ContentID: contentID, Path: path})
+		}
+		return prefix + quote + "cid:" + contentID + quote
+	})
+	if embedErr != nil {
+		return "", nil, embedErr
+	}
+
+	return rewritten, resources, nil
+}
+
+// isLocalReference reports whether an image src refers to a local file.
+func isLocalReference(src string) bool {
+	src = strings.TrimSpace(src)
+	if src == "" || strings.HasPrefix(src, "//") {
+		return false
+	}
+	if strings.HasPrefix(strings.ToLower(src), "file://") {
+		return true
+	}
+	// Any other URL scheme (http:, https:, data:, cid:) is not a local path.
+	// A single letter before the colon is a Windows drive, not a scheme.
+	if i := strings.Index(src, ":"); i > 1 && !strings.ContainsAny(src[:i], `/\`) {
+		return false
+	}
+	return true
+}
+
+// newContentID generates a globally unique Content-ID for an inline file.
+// Parameters:
+// - fileName: The file name, kept in the ID to ease debugging.
+func newContentID(fileName string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate Content-ID: %v", err)
+	}
+	safeName := strings.Map(func(r rune) rune {
+		if r < 0x80 && (r == '.' || r == '-' || r == '_' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return r
+		}
+		return '_'
+	}, fileName)
+	return fmt.Sprintf("%s.%s@dhanu", safeName, hex.EncodeToString(random)), nil
+}
+
+// addInlineResource adds a file as an inline part that HTML can reference by Content-ID.
+// Parameters:
+// - writer: The MIME multipart writer of the multipart/related part.
+// - resource: The inline resource to embed.
+func (es *DhanuEmailService) addInlineResource(writer *multipart.Writer, resource InlineResource) error {
+	file, err := os.Open(resource.Path)
+	if err != nil {
+		return attachmentError(resource.Path, err)
+	}
+	defer file.Close()
+
+	fileName := sanitizeFileName(filepath.Base(resource.Path))
+	mimeType, err := detectContentType(file, fileName)
+	if err != nil {
+		return attachmentError(resource.Path, err)
+	}
+
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Type", mimeType)
+	partHeader.Set("Content-Transfer-Encoding", "base64")
+	partHeader.Set("Content-ID", "<"+resource.ContentID+">")
+	partHeader.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+
+	part, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return fmt.Errorf("failed to create inline resource part: %v", err)
+	}
+	if err := writeBase64(part, file); err != nil {
+		return attachmentError(resource.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEmbedLocalImages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "img"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	logo := filepath.Join(dir, "img", "logo.png")
+	chart := filepath.Join(dir, "chart.png")
+	for _, path := range []string{logo, chart} {
+		if err := os.WriteFile(path, testInlineImage, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	htmlBody := `<p><img src="img/logo.png" alt="logo"></p>` +
+		`<img class='wide' src='` + chart + `'>` +
+		`<IMG SRC="file://` + logo + `">` +
+		`<img src="https://example.com/remote.png">` +
+		`<img src="http://example.com/remote.png">` +
+		`<img src="//cdn.example.com/remote.png">` +
+		`<img src="data:image/png;base64,iVBORw0KGgo=">` +
+		`<img src="cid:existing@example.com">`
+
+	rewritten, resources, err := EmbedLocalImages(htmlBody, dir)
+	if err != nil {
+		t.Fatalf("EmbedLocalImages() error = %v", err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("EmbedLocalImages() returned %d resources, want 2: %+v", len(resources), resources)
+	}
+	if resources[0].Path != logo || resources[1].Path != chart {
+		t.Errorf("resource paths = %q, %q, want %q, %q", resources[0].Path, resources[1].Path, logo, chart)
+	}
+
+	srcs := regexp.MustCompile(`(?i)src=["']([^"']*)["']`).FindAllStringSubmatch(rewritten, -1)
+	want := []string{
+		"cid:" + resources[0].ContentID,
+		"cid:" + resources[1].ContentID,
+		"cid:" + resources[0].ContentID, // The same file reuses its Content-ID.
+		"https://example.com/remote.png",
+		"http://example.com/remote.png",
+		"//cdn.example.com/remote.png",
+		"data:image/png;base64,iVBORw0KGgo=",
+		"cid:existing@example.com",
+	}
+	if len(srcs) != len(want) {
+		t.Fatalf("rewritten HTML has %d src attributes, want %d:\n%s", len(srcs), len(want), rewritten)
+	}
+	for i, src := range srcs {
+		if src[1] != want[i] {
+			t.Errorf("src %d = %q, want %q", i, src[1], want[i])
+		}
+	}
+	if !strings.Contains(rewritten, `<img class='wide' src='cid:`) || !strings.Contains(rewritten, `alt="logo"`) {
+		t.Errorf("rewritten HTML lost its quoting or attributes:\n%s", rewritten)
+	}
+
+	// The rewritten HTML and its resources build a message whose related
+	// part carries each image under the referenced Content-ID.
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret")
+	var out bytes.Buffer
+	err = service.WriteMessage(&out, &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "Inline images",
+		HTMLBody:   rewritten,
+		Inline:     resources,
+	})
+	if err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	contentIDs := inlineContentIDs(t, out.Bytes())
+	for _, resource := range resources {
+		if !contentIDs["<"+resource.ContentID+">"] {
+			t.Errorf("message has no part with Content-ID <%s>; found %v", resource.ContentID, contentIDs)
+		}
+	}
+}
+
+func TestEmbedLocalImagesMissingFile(t *testing.T) {
+	htmlBody := `<img src="https://example.com/remote.png"><img src="missing.png">`
+	rewritten, resources, err := EmbedLocalImages(htmlBody, t.TempDir())
+	if err == nil {
+		t.Fatalf("EmbedLocalImages() = %q, %+v, want an error", rewritten, resources)
+	}
+	if !strings.Contains(err.Error(), "missing.png") {
+		t.Errorf("error %q does not name the missing image", err)
+	}
+}
+
+// inlineContentIDs returns the Content-ID headers of every part of a message.
+func inlineContentIDs(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("net/mail cannot parse the message: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid Content-Type: %v", err)
+	}
+	ids := make(map[string]bool)
+	var walk func(reader *multipart.Reader)
+	walk = func(reader *multipart.Reader) {
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				return
+			}
+			if id := part.Header.Get("Content-ID"); id != "" {
+				ids[id] = true
+			}
+			mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+				walk(multipart.NewReader(part, params["boundary"]))
+			}
+		}
+	}
+	walk(multipart.NewReader(msg.Body, params["boundary"]))
+	return ids
+}
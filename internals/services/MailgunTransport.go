@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// DefaultMailgunEndpoint is the base URL of Mailgun's US region API;
+// domains in the EU region use "https://api.eu.mailgun.net".
+const DefaultMailgunEndpoint = "https://api.mailgun.net"
+
+// MailgunTransport sends messages through Mailgun's MIME messages API, which
+// accepts the complete message, so DKIM, PGP/MIME and S/MIME signatures
+// survive unchanged.
+type MailgunTransport struct {
+	apiKey   string
+	domain   string
+	endpoint string
+	client   *http.Client
+}
+
+// NewMailgunTransport creates a Mailgun transport.
+// Parameters:
+// - apiKey: A Mailgun API key or domain sending key.
+// - domain: The sending domain configured in Mailgun, e.g. "mg.example.com".
+// - endpoint: The API base URL; empty uses DefaultMailgunEndpoint.
+func NewMailgunTransport(apiKey, domain, endpoint string) *MailgunTransport {
+	return &MailgunTransport{
+		apiKey:   apiKey,
+		domain:   domain,
+		endpoint: apiEndpoint(endpoint, DefaultMailgunEndpoint),
+		client:   newAPIClient(),
+	}
+}
+
+// Deliver uploads the message with one messages.mime request.
+// Parameters:
+// - ctx: Cancels the request when done.
+// - from: The envelope sender (unused; Mailgun sets its own bounce address).
+// - to: The envelope recipients, including any Bcc addresses.
+// - message: The complete message with CRLF line endings.
+func (t *MailgunTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	if t.domain == "" {
+		return fmt.Errorf("the %s transport requires a sending domain", TransportMailgun)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, recipient := range to {
+		if err := form.WriteField("to", envelopeAddress(recipient)); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("message", "message.eml")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	target := t.endpoint + "/v3/" + url.PathEscape(t.domain) + "/messages.mime"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, &body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", t.apiKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return doAPIRequest(t.client, TransportMailgun, req)
+}
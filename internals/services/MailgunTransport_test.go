@@ -0,0 +1,68 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMailgunTransport(t *testing.T) {
+	standIn := newAPIStandIn(t, http.StatusOK, `{"id":"<1@mg.example.com>","message":"Queued"}`)
+	envelope, data := composeAPITestMessage(t)
+
+	transport := NewMailgunTransport("key-test", "mg.example.com", standIn.URL)
+	if err := transport.Deliver(context.Background(), "reports@example.com", envelope, data); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	req := standIn.request(t)
+	if req.Method != http.MethodPost || req.Path != "/v3/mg.example.com/messages.mime" {
+		t.Errorf("request = %s %s, want POST /v3/mg.example.com/messages.mime", req.Method, req.Path)
+	}
+	httpReq := &http.Request{Header: req.Header}
+	if user, password, ok := httpReq.BasicAuth(); !ok || user != "api" || password != "key-test" {
+		t.Errorf("basic auth = %q/%q, want api/key-test", user, password)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("Content-Type = %q, want multipart/form-data", req.Header.Get("Content-Type"))
+	}
+	form, err := multipart.NewReader(bytes.NewReader(req.Body), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("invalid form: %v", err)
+	}
+	// Bcc recipients are only in the envelope: the "to" fields, not the message.
+	wantTo := []string{"alice@example.com", "carol@example.com", "hidden@example.com"}
+	if !reflect.DeepEqual(form.Value["to"], wantTo) {
+		t.Errorf("to = %q, want %q", form.Value["to"], wantTo)
+	}
+	files := form.File["message"]
+	if len(files) != 1 {
+		t.Fatalf("form has %d message files, want 1", len(files))
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	uploaded, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(uploaded) != data {
+		t.Error("uploaded message differs from the composed message")
+	}
+	if strings.Contains(string(uploaded), "hidden@example.com") {
+		t.Error("uploaded message reveals the Bcc recipient")
+	}
+	if !strings.Contains(string(uploaded), "Content-Id: <logo@example.com>") {
+		t.Error("uploaded message is missing the inline image's Content-ID")
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+)
+
+// Header is a custom header field added to a message.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Message describes a complete email independently of how it is delivered.
+// At least one of TextBody and HTMLBody must be set; when only HTMLBody is set,
+// a plain-text alternative is generated from it.
+type Message struct {
+	// From overrides the From header, e.g. "Reports <reports@example.com>".
+	// When empty, the service's configured sender is used. The SMTP envelope
+	// sender is always the configured account.
+	From string
+
+	Recipients
+
+	Subject     string           // Line breaks are replaced by spaces.
+	TextBody    string           // Plain-text body.
+	HTMLBody    string           // HTML body, sent with a plain-text alternative.
+	Attachments []string         // File paths to attach.
+	Inline      []InlineResource // Resources referenced from HTMLBody by Content-ID.
+	Headers     []Header         // Additional header fields, written in order.
+
+	// MessageID is the Message-ID without angle brackets. When empty, a unique
+	// ID is generated each time the message is built; the field stays empty.
+	MessageID string
+
+	// Sign and Encrypt protect the message with the service's Protector.
+	// Headers such as the subject stay readable in encrypted messages.
+	Sign    bool
+	Encrypt bool
+}
+
+// Validate checks that the message has recipients and a body.
+func (m *Message) Validate() error {
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return errors.New("message has no recipients")
+	}
+	if m.TextBody == "" && m.HTMLBody == "" {
+		return errors.New("message has no body")
+	}
+	if len(m.Inline) > 0 && m.HTMLBody == "" {
+		return errors.New("inline resources require an HTML body")
+	}
+	return nil
+}
+
+// MessageBuilder builds a Message through chained calls, for example:
+//
+//	msg, err := services.NewMessage().
+//		To("team@example.com").
+//		Subject("Nightly build").
+//		HTML(report).
+//		Attach("build.log").
+//		Build()
+type MessageBuilder struct {
+	msg Message
+}
+
+// NewMessage starts building a new message.
+func NewMessage() *MessageBuilder {
+	return &MessageBuilder{}
+}
+
+// From sets the From header, overriding the service's configured sender.
+func (b *MessageBuilder) From(address string) *MessageBuilder {
+	b.msg.From = address
+	return b
+}
+
+// To adds primary recipients.
+func (b *MessageBuilder) To(addresses ...string) *MessageBuilder {
+	b.msg.To = append(b.msg.To, addresses...)
+	return b
+}
+
+// Cc adds carbon-copy recipients.
+func (b *MessageBuilder) Cc(addresses ...string) *MessageBuilder {
+	b.msg.Cc = append(b.msg.Cc, addresses...)
+	return b
+}
+
+// Bcc adds blind carbon-copy recipients, which never appear in the headers.
+func (b *MessageBuilder) Bcc(addresses ...string) *MessageBuilder {
+	b.msg.Bcc = append(b.msg.Bcc, addresses...)
+	return b
+}
+
+// ReplyTo adds addresses replies should be sent to.
+func (b *MessageBuilder) ReplyTo(addresses ...string) *MessageBuilder {
+	b.msg.ReplyTo = append(b.msg.ReplyTo, addresses...)
+	return b
+}
+
+// Subject sets the subject.
+func (b *MessageBuilder) Subject(subject string) *MessageBuilder {
+	b.msg.Subject = subject
+	return b
+}
+
+// Text sets the plain-text body.
+func (b *MessageBuilder) Text(body string) *MessageBuilder {
+	b.msg.TextBody = body
+	return b
+}
+
+// HTML sets the HTML body.
+func (b *MessageBuilder) HTML(body string) *MessageBuilder {
+	b.msg.HTMLBody = body
+	return b
+}
+
+// Attach adds file attachments.
+func (b *MessageBuilder) Attach(paths ...string) *MessageBuilder {
+	b.msg.Attachments = append(b.msg.Attachments, paths...)
+	return b
+}
+
+// Inline adds resources referenced from the HTML body by Content-ID.
+func (b *MessageBuilder) Inline(resources ...InlineResource) *MessageBuilder {
+	b.msg.Inline = append(b.msg.Inline, resources...)
+	return b
+}
+
+// MessageID sets the Message-ID (without angle brackets) instead of generating one.
+func (b *MessageBuilder) MessageID(id string) *MessageBuilder {
+	b.msg.MessageID = id
+	return b
+}
+
+// Sign requests the message to be signed.
+func (b *MessageBuilder) Sign() *MessageBuilder {
+	b.msg.Sign = true
+	return b
+}
+
+// Encrypt requests the message to be encrypted to every recipient.
+func (b *MessageBuilder) Encrypt() *MessageBuilder {
+	b.msg.Encrypt = true
+	return b
+}
+
+// Header adds a custom header field. Date and X-Mailer may be overridden
+// this way; headers with dedicated fields (From, To, Subject, ...) may not.
+func (b *MessageBuilder) Header(name, value string) *MessageBuilder {
+	b.msg.Headers = append(b.msg.Headers, Header{Name: name, Value: value})
+	return b
+}
+
+// Build validates and returns the message.
+func (b *MessageBuilder) Build() (*Message, error) {
+	msg := b.msg
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMessageBuilderMatchesAdapters sends each message once through a legacy
+// SendDhanuEmail* method and once as a MessageBuilder message, and checks
+// that both produce the same envelope, headers and MIME structure.
+func TestMessageBuilderMatchesAdapters(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(report, []byte("name,total\r\na,1\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(logo, testInlineImage, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inline := InlineResource{ContentID: "logo@example.com", Path: logo}
+	const htmlBody = `<p>Totals are <b>attached</b>.</p><img src="cid:logo@example.com">`
+
+	tests := []struct {
+		name    string
+		adapter func(DhanuEmailServiceInterface) error
+		builder *MessageBuilder
+	}{
+		{
+			name: "plain text",
+			adapter: func(s DhanuEmailServiceInterface) error {
+				return s.SendDhanuEmail([]string{"a@example.com", "b@example.com"}, "Plain", "Hello", false)
+			},
+			builder: NewMessage().To("a@example.com", "b@example.com").Subject("Plain").Text("Hello"),
+		},
+		{
+			name: "HTML",
+			adapter: func(s DhanuEmailServiceInterface) error {
+				return s.SendDhanuEmail([]string{"a@example.com"}, "HTML", htmlBody, true)
+			},
+			builder: NewMessage().To("a@example.com").Subject("HTML").HTML(htmlBody),
+		},
+		{
+			name: "attachments",
+			adapter: func(s DhanuEmailServiceInterface) error {
+				return s.SendDhanuEmailWithAttachments([]string{"a@example.com"}, "Report", "See attached.", false, []string{report, logo})
+			},
+			builder: NewMessage().To("a@example.com").Subject("Report").Text("See attached.").Attach(report, logo),
+		},
+		{
+			name: "inline resources",
+			adapter: func(s DhanuEmailServiceInterface) error {
+				return s.SendDhanuEmailWithInlineResources([]string{"a@example.com"}, "Inline", htmlBody, []string{report}, []InlineResource{inline})
+			},
+			builder: NewMessage().To("a@example.com").Subject("Inline").HTML(htmlBody).Attach(report).Inline(inline),
+		},
+		{
+			name: "recipients",
+			adapter: func(s DhanuEmailServiceInterface) error {
+				recipients := Recipients{
+					To:      []string{"Ann <a@example.com>"},
+					Cc:      []string{"c@example.com"},
+					Bcc:     []string{"hidden@example.com"},
+					ReplyTo: []string{"replies@example.com"},
+				}
+				return s.SendDhanuEmailToRecipients(recipients, "Grüße", "Hello", false, []string{report}, nil)
+			},
+			builder: NewMessage().To("Ann <a@example.com>").Cc("c@example.com").Bcc("hidden@example.com").
+				ReplyTo("replies@example.com").Subject("Grüße").Text("Hello").Attach(report),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTP{}
+			host, port := server.start(t)
+			service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+
+			if err := tt.adapter(service); err != nil {
+				t.Fatalf("adapter error = %v", err)
+			}
+			msg, err := tt.builder.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if err := service.Send(context.Background(), msg); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 2 {
+				t.Fatalf("server received %d messages, want 2", len(messages))
+			}
+			adapted, built := messages[0], messages[1]
+			if adapted.Mail != built.Mail || !reflect.DeepEqual(adapted.Rcpt, built.Rcpt) {
+				t.Errorf("envelopes differ:\nadapter %s %q\nbuilder %s %q", adapted.Mail, adapted.Rcpt, built.Mail, built.Rcpt)
+			}
+			want, got := mimeStructure(t, adapted.Data), mimeStructure(t, built.Data)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("MIME structures differ:\nadapter:\n%s\nbuilder:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+// mimeStructure describes a message as one line per header and MIME part,
+// leaving out values that change between builds: the Date, the Message-ID
+// and the multipart boundaries.
+func mimeStructure(t *testing.T, data string) []string {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("net/mail cannot parse the message: %v", err)
+	}
+	var lines []string
+	for _, name := range []string{"From", "To", "Cc", "Bcc", "Reply-To", "Subject", "X-Mailer"} {
+		lines = append(lines, name+": "+msg.Header.Get(name))
+	}
+
+	var walk func(depth int, header map[string][]string, body io.Reader)
+	walk = func(depth int, header map[string][]string, body io.Reader) {
+		get := func(name string) string {
+			if values := header[name]; len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		}
+		mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+		if err != nil {
+			t.Fatalf("invalid Content-Type %q: %v", get("Content-Type"), err)
+		}
+		indent := strings.Repeat("  ", depth)
+		if strings.HasPrefix(mediaType, "multipart/") {
+			lines = append(lines, indent+mediaType)
+			reader := multipart.NewReader(body, params["boundary"])
+			for {
+				part, err := reader.NextRawPart()
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					t.Fatalf("failed to read MIME part: %v", err)
+				}
+				walk(depth+1, part.Header, part)
+			}
+		}
+
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("failed to read %s part: %v", mediaType, err)
+		}
+		switch get("Content-Transfer-Encoding") {
+		case "base64":
+			raw, err = io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw)))
+		case "quoted-printable":
+			raw, err = io.ReadAll(quotedprintable.NewReader(bytes.NewReader(raw)))
+		}
+		if err != nil {
+			t.Fatalf("failed to decode %s part: %v", mediaType, err)
+		}
+		lines = append(lines, fmt.Sprintf("%s%s encoding=%s disposition=%q id=%q body=%q",
+			indent, get("Content-Type"), get("Content-Transfer-Encoding"),
+			get("Content-Disposition"), get("Content-Id"), raw))
+	}
+	walk(0, msg.Header, msg.Body)
+	return lines
+}
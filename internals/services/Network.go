@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// IPFamily restricts which IP version the SMTP connection uses.
+type IPFamily string
+
+const (
+	// IPFamilyAuto connects over IPv4 or IPv6, whichever the server's addresses and the network allow.
+	IPFamilyAuto IPFamily = "auto"
+	// IPFamilyIPv4 only connects over IPv4.
+	IPFamilyIPv4 IPFamily = "ipv4"
+	// IPFamilyIPv6 only connects over IPv6.
+	IPFamilyIPv6 IPFamily = "ipv6"
+)
+
+// ParseIPFamily converts a configuration value into an IPFamily.
+// An empty value is treated as IPFamilyAuto; "4" and "6" are accepted as well.
+// Parameters:
+// - value: The configured IP family.
+func ParseIPFamily(value string) (IPFamily, error) {
+	switch family := IPFamily(strings.ToLower(strings.TrimSpace(value))); family {
+	case "", IPFamilyAuto:
+		return IPFamilyAuto, nil
+	case IPFamilyIPv4, "4":
+		return IPFamilyIPv4, nil
+	case IPFamilyIPv6, "6":
+		return IPFamilyIPv6, nil
+	default:
+		return "", fmt.Errorf("unknown IP family %q (expected auto, ipv4 or ipv6)", value)
+	}
+}
+
+// network returns the network name to dial for the configured IP family.
+func (t *smtpTransport) network() string {
+	switch t.ipFamily {
+	case IPFamilyIPv4:
+		return "tcp4"
+	case IPFamilyIPv6:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
+}
+
+// newDialer returns the dialer for the SMTP connection, bound to the
+// configured local address if there is one.
+func (t *smtpTransport) newDialer() (*net.Dialer, error) {
+	dialer := &net.Dialer{Timeout: t.dialTimeout}
+	if t.localAddr == "" {
+		return dialer, nil
+	}
+	ip, err := t.localIP()
+	if err != nil {
+		return nil, err
+	}
+	dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	return dialer, nil
+}
+
+// localIP resolves the configured local address, which is either an IP
+// address or the name of a network interface. An interface is bound to its
+// first address of the configured family, preferring IPv4 when either will do.
+func (t *smtpTransport) localIP() (net.IP, error) {
+	if ip := net.ParseIP(t.localAddr); ip != nil {
+		if !t.familyAllows(ip) {
+			return nil, fmt.Errorf("local address %s is not an %s address", ip, t.ipFamily)
+		}
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(t.localAddr)
+	if err != nil {
+		return nil, fmt.Errorf("local address %q is neither an IP address nor a network interface: %v", t.localAddr, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the addresses of %s: %v", t.localAddr, err)
+	}
+	var candidate net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		// Link-local addresses can't reach a mail server beyond the local link.
+		if !ok || ipNet.IP.IsLinkLocalUnicast() || !t.familyAllows(ipNet.IP) {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if candidate == nil {
+			candidate = ipNet.IP
+		}
+	}
+	if candidate == nil {
+		return nil, fmt.Errorf("network interface %s has no usable address", t.localAddr)
+	}
+	return candidate, nil
+}
+
+// familyAllows reports whether ip belongs to the configured IP family.
+// Parameters:
+// - ip: The address to check.
+func (t *smtpTransport) familyAllows(ip net.IP) bool {
+	switch t.ipFamily {
+	case IPFamilyIPv4:
+		return ip.To4() != nil
+	case IPFamilyIPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// helloName returns the name sent with EHLO or HELO: the configured name,
+// otherwise the machine's fully qualified domain name, otherwise the address
+// literal of the connection's local address, as RFC 5321 asks for hosts
+// without a meaningful name.
+// Parameters:
+// - ctx: Bounds the DNS lookups of the machine's name, together with the dial timeout.
+// - conn: The connection to the SMTP server.
+func (t *smtpTransport) helloName(ctx context.Context, conn net.Conn) string {
+	if t.hello != "" {
+		return t.hello
+	}
+
+	t.fqdnMu.Lock()
+	fqdn, resolved := t.fqdn, t.fqdnResolved
+	t.fqdnMu.Unlock()
+	if !resolved {
+		lookupCtx := ctx
+		if t.dialTimeout > 0 {
+			var cancel context.CancelFunc
+			lookupCtx, cancel = context.WithTimeout(ctx, t.dialTimeout)
+			defer cancel()
+		}
+		fqdn = localFQDN(lookupCtx)
+		// Only remember complete answers; an interrupted lookup is tried
+		// again on the next connection.
+		if lookupCtx.Err() == nil {
+			t.fqdnMu.Lock()
+			t.fqdn, t.fqdnResolved = fqdn, true
+			t.fqdnMu.Unlock()
+		}
+	}
+	if fqdn != "" {
+		return fqdn
+	}
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		if addr.IP.To4() != nil {
+			return "[" + addr.IP.String() + "]"
+		}
+		return "[IPv6:" + addr.IP.String() + "]"
+	}
+	return "localhost"
+}
+
+// localFQDN looks up the fully qualified domain name of this machine. It
+// returns "" if the host name has no domain and none is found in DNS before
+// ctx is done.
+// Parameters:
+// - ctx: Bounds the DNS lookups.
+func localFQDN(ctx context.Context) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return ""
+	}
+	if isQualified(host) {
+		return host
+	}
+	if cname, err := net.DefaultResolver.LookupCNAME(ctx, host); err == nil {
+		if name := strings.TrimSuffix(cname, "."); isQualified(name) {
+			return name
+		}
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			if name = strings.TrimSuffix(name, "."); isQualified(name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// isQualified reports whether name is a domain name other than a localhost alias.
+// Parameters:
+// - name: The host name to check.
+func isQualified(name string) bool {
+	return strings.Contains(name, ".") && !strings.HasPrefix(strings.ToLower(name), "localhost.")
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHelloNameConfigured(t *testing.T) {
+	server := &fakeSMTP{}
+	host, port := server.start(t)
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone), WithHelloName("mail.example.com"))
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "Hello",
+		TextBody:   "Hello",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := server.Commands()[0]; got != "EHLO mail.example.com" {
+		t.Errorf("first command = %q, want EHLO mail.example.com", got)
+	}
+}
+
+func TestHelloNameLookupHonoursContext(t *testing.T) {
+	server := &fakeSMTP{}
+	host, port := server.start(t)
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transport := &smtpTransport{dialTimeout: time.Minute}
+	start := time.Now()
+	name := transport.helloName(ctx, conn)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("helloName() took %v with a cancelled context", elapsed)
+	}
+
+	hostname, _ := os.Hostname()
+	if isQualified(hostname) {
+		if name != hostname {
+			t.Errorf("helloName() = %q, want the qualified host name %q", name, hostname)
+		}
+		return
+	}
+	if name != "[127.0.0.1]" {
+		t.Errorf("helloName() = %q, want the address literal [127.0.0.1]", name)
+	}
+	if transport.fqdnResolved {
+		t.Error("an interrupted lookup was cached")
+	}
+}
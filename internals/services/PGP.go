@@ -0,0 +1,385 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// pgpConfig makes signatures use SHA-256, matching the micalg parameter.
+var pgpConfig = &packet.Config{DefaultHash: crypto.SHA256}
+
+// KeyInfo describes a public key stored in a Keyring.
+type KeyInfo struct {
+	Fingerprint string    // Upper-case hex fingerprint of the primary key.
+	KeyID       string    // Upper-case hex 64-bit key ID.
+	Identities  []string  // User IDs, e.g. "Jane Doe <jane@example.com>".
+	Created     time.Time // Creation time of the primary key.
+	CanEncrypt  bool      // Whether the key has a valid encryption (sub)key.
+}
+
+// Keyring is a directory of OpenPGP public keys, one armored file per key
+// named after its fingerprint.
+type Keyring struct {
+	dir string
+}
+
+// NewKeyring opens the keyring stored in a directory. The directory is created on first import.
+// Parameters:
+// - dir: The keyring directory.
+func NewKeyring(dir string) *Keyring {
+	return &Keyring{dir: dir}
+}
+
+// Import adds the public keys read from r to the keyring, replacing keys
+// with the same fingerprint. Private key material is never stored.
+// Parameters:
+// - r: Armored or binary OpenPGP key data.
+func (k *Keyring) Import(r io.Reader) ([]KeyInfo, error) {
+	entities, err := readEntities(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(k.dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	var imported []KeyInfo
+	for _, entity := range entities {
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := entity.Serialize(w); err != nil {
+			return nil, fmt.Errorf("failed to serialize key: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		info := keyInfo(entity)
+		if err := os.WriteFile(filepath.Join(k.dir, info.Fingerprint+".asc"), buf.Bytes(), 0o644); err != nil {
+			return nil, err
+		}
+		imported = append(imported, info)
+	}
+	return imported, nil
+}
+
+// List returns the keys in the keyring, sorted by their first identity.
+func (k *Keyring) List() ([]KeyInfo, error) {
+	entities, _, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]KeyInfo, 0, len(entities))
+	for _, entity := range entities {
+		infos = append(infos, keyInfo(entity))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.Join(infos[i].Identities, ",") < strings.Join(infos[j].Identities, ",")
+	})
+	return infos, nil
+}
+
+// Remove deletes the keys matching a fingerprint, key ID or email address.
+// Parameters:
+// - query: The fingerprint, key ID (both case-insensitive) or email address.
+func (k *Keyring) Remove(query string) ([]KeyInfo, error) {
+	entities, paths, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	var removed []KeyInfo
+	for i, entity := range entities {
+		if !matchesKey(entity, query) {
+			continue
+		}
+		if err := os.Remove(paths[i]); err != nil {
+			return removed, err
+		}
+		removed = append(removed, keyInfo(entity))
+	}
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("no key matches %q", query)
+	}
+	return removed, nil
+}
+
+// Lookup returns the newest key that can encrypt to an address.
+// Parameters:
+// - address: The recipient address, optionally in "Name <user@example.com>" form.
+func (k *Keyring) Lookup(address string) (*openpgp.Entity, error) {
+	entities, _, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	email := envelopeAddress(address)
+	now := time.Now()
+	var found *openpgp.Entity
+	for _, entity := range entities {
+		if !hasEmail(entity, email) {
+			continue
+		}
+		if _, ok := entity.EncryptionKey(now); !ok {
+			continue
+		}
+		if found == nil || entity.PrimaryKey.CreationTime.After(found.PrimaryKey.CreationTime) {
+			found = entity
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no usable PGP key for %s", email)
+	}
+	return found, nil
+}
+
+// load reads every key file in the keyring directory.
+func (k *Keyring) load() ([]*openpgp.Entity, []string, error) {
+	files, err := filepath.Glob(filepath.Join(k.dir, "*.asc"))
+	if err != nil {
+		return nil, nil, err
+	}
+	var entities []*openpgp.Entity
+	var paths []string
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		list, err := openpgp.ReadArmoredKeyRing(file)
+		file.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read key %s: %v", path, err)
+		}
+		for _, entity := range list {
+			entities = append(entities, entity)
+			paths = append(paths, path)
+		}
+	}
+	return entities, paths, nil
+}
+
+// LoadPGPSigningKey reads a private key used to sign outgoing mail.
+// Parameters:
+// - path: The armored or binary private key file.
+// - passphrase: The passphrase protecting the key; ignored for unprotected keys.
+func LoadPGPSigningKey(path, passphrase string) (*openpgp.Entity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP signing key: %v", err)
+	}
+	defer file.Close()
+
+	entities, err := readEntities(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			if passphrase == "" {
+				return nil, fmt.Errorf("PGP signing key %s is protected by a passphrase", path)
+			}
+			if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+				return nil, fmt.Errorf("failed to unlock PGP signing key: %v", err)
+			}
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("%s contains no private key", path)
+}
+
+// readEntities reads armored or binary OpenPGP keys.
+func readEntities(r io.Reader) (openpgp.EntityList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entities openpgp.EntityList
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP keys: %v", err)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("no PGP keys found")
+	}
+	return entities, nil
+}
+
+// keyInfo summarises an entity.
+func keyInfo(entity *openpgp.Entity) KeyInfo {
+	info := KeyInfo{
+		Fingerprint: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+		KeyID:       entity.PrimaryKey.KeyIdString(),
+		Created:     entity.PrimaryKey.CreationTime,
+	}
+	for name := range entity.Identities {
+		info.Identities = append(info.Identities, name)
+	}
+	sort.Strings(info.Identities)
+	_, info.CanEncrypt = entity.EncryptionKey(time.Now())
+	return info
+}
+
+// matchesKey reports whether query is the entity's fingerprint, key ID or one of its email addresses.
+func matchesKey(entity *openpgp.Entity, query string) bool {
+	query = strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(query, "0x"), " ", ""))
+	info := keyInfo(entity)
+	if query == info.Fingerprint || query == info.KeyID {
+		return true
+	}
+	return hasEmail(entity, query)
+}
+
+// hasEmail reports whether one of the entity's identities uses the address.
+func hasEmail(entity *openpgp.Entity, email string) bool {
+	for _, identity := range entity.Identities {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// PGPProtector signs and encrypts messages as PGP/MIME (RFC 3156).
+type PGPProtector struct {
+	keyring *Keyring
+	signer  *openpgp.Entity
+}
+
+// NewPGPProtector creates a PGP/MIME protector.
+// Parameters:
+// - keyring: The keyring holding the recipients' public keys; required to encrypt.
+// - signer: The unlocked private key used to sign; required to sign.
+// When the signer has an encryption subkey, messages are also encrypted to it so the sender can read them.
+func NewPGPProtector(keyring *Keyring, signer *openpgp.Entity) *PGPProtector {
+	return &PGPProtector{keyring: keyring, signer: signer}
+}
+
+// Protect wraps the message in multipart/signed or multipart/encrypted. When
+// both are requested the content is signed and encrypted in one OpenPGP
+// message (RFC 3156 section 6.2).
+// Parameters:
+// - message: The complete message with CRLF line endings.
+// - recipients: The envelope recipients to encrypt to.
+// - sign: Whether to sign the message.
+// - encrypt: Whether to encrypt the message.
+func (p *PGPProtector) Protect(message string, recipients []string, sign, encrypt bool) (string, error) {
+	if !sign && !encrypt {
+		return message, nil
+	}
+	if sign && p.signer == nil {
+		return "", errors.New("PGP signing requested but no signing key is configured")
+	}
+	outer, entity, err := splitEntity(message)
+	if err != nil {
+		return "", err
+	}
+	if encrypt {
+		return p.encrypt(outer, entity, recipients, sign)
+	}
+	return p.sign(outer, entity)
+}
+
+// sign wraps the entity in multipart/signed with a detached signature (RFC 3156 section 5).
+func (p *PGPProtector) sign(outer, entity string) (string, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSignText(&signature, p.signer, strings.NewReader(entity), pgpConfig); err != nil {
+		return "", fmt.Errorf("PGP signing failed: %v", err)
+	}
+
+	signaturePart := "Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n" +
+		"Content-Description: OpenPGP digital signature\r\n\r\n" +
+		toCRLF(signature.String())
+	return outer + signedMultipart(entity, "application/pgp-signature", "pgp-sha256", signaturePart), nil
+}
+
+// encrypt wraps the entity in multipart/encrypted (RFC 3156 section 4).
+func (p *PGPProtector) encrypt(outer, entity string, recipients []string, sign bool) (string, error) {
+	if p.keyring == nil {
+		return "", errors.New("PGP encryption requested but no keyring is configured")
+	}
+	var keys []*openpgp.Entity
+	var missing []error
+	for _, recipient := range recipients {
+		key, err := p.keyring.Lookup(recipient)
+		if err != nil {
+			missing = append(missing, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(missing) > 0 {
+		return "", errors.Join(missing...)
+	}
+	if p.signer != nil {
+		if _, ok := p.signer.EncryptionKey(time.Now()); ok {
+			keys = append(keys, p.signer)
+		}
+	}
+	var signer *openpgp.Entity
+	if sign {
+		signer = p.signer
+	}
+
+	var ciphertext bytes.Buffer
+	armored, err := armor.Encode(&ciphertext, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openpgp.Encrypt(armored, keys, signer, nil, pgpConfig)
+	if err != nil {
+		return "", fmt.Errorf("PGP encryption failed: %v", err)
+	}
+	if _, err := io.WriteString(plaintext, entity); err != nil {
+		return "", fmt.Errorf("PGP encryption failed: %v", err)
+	}
+	if err := plaintext.Close(); err != nil {
+		return "", fmt.Errorf("PGP encryption failed: %v", err)
+	}
+	if err := armored.Close(); err != nil {
+		return "", err
+	}
+
+	boundary := randomBoundary()
+	var b strings.Builder
+	b.WriteString(outer)
+	b.WriteString(formatHeader("Content-Type", fmt.Sprintf(
+		`multipart/encrypted; boundary="%s"; protocol="application/pgp-encrypted"`, boundary)))
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	b.WriteString("Content-Description: PGP/MIME version identification\r\n\r\n")
+	b.WriteString("Version: 1\r\n")
+	fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	b.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	b.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n")
+	b.WriteString(toCRLF(ciphertext.String()))
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.String(), nil
+}
+
+// toCRLF converts LF line endings, as produced by the armor encoder, to CRLF.
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
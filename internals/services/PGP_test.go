@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// newPGPEntity creates an Ed25519 key with an encryption subkey for one identity.
+// Parameters:
+// - created: The key's creation time.
+func newPGPEntity(t *testing.T, name, email string, created time.Time) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", email, &packet.Config{
+		Algorithm: packet.PubKeyAlgoEdDSA,
+		Time:      func() time.Time { return created },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+// armoredKeys returns the public keys of entities in one armored block, as
+// gpg --export --armor writes them, or their private keys when private is set.
+func armoredKeys(t *testing.T, private bool, entities ...*openpgp.Entity) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	blockType := openpgp.PublicKeyType
+	if private {
+		blockType = openpgp.PrivateKeyType
+	}
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entity := range entities {
+		if private {
+			err = entity.SerializePrivate(w, nil)
+		} else {
+			err = entity.Serialize(w)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestKeyring(t *testing.T) {
+	now := time.Now().Add(-time.Hour)
+	alice := newPGPEntity(t, "Alice", "alice@example.com", now.Add(-time.Hour))
+	aliceNew := newPGPEntity(t, "Alice", "alice@example.com", now)
+	bob := newPGPEntity(t, "Bob", "bob@example.com", now)
+	keyring := NewKeyring(t.TempDir())
+
+	// Private key material is reduced to the public key on import.
+	imported, err := keyring.Import(bytes.NewReader(armoredKeys(t, true, bob)))
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(imported) != 1 || imported[0].Identities[0] != "Bob <bob@example.com>" || !imported[0].CanEncrypt {
+		t.Fatalf("Import() = %+v, want Bob's encryption-capable key", imported)
+	}
+	if _, err := keyring.Import(bytes.NewReader(armoredKeys(t, false, alice, aliceNew))); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if _, err := keyring.Import(strings.NewReader("not a key")); err == nil {
+		t.Error("Import() of garbage succeeded")
+	}
+
+	listed, err := keyring.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(listed) != 3 || listed[0].Identities[0] != "Alice <alice@example.com>" || listed[2].Identities[0] != "Bob <bob@example.com>" {
+		t.Fatalf("List() = %+v, want both Alice keys then Bob", listed)
+	}
+	stored, err := keyring.Lookup("Bob <bob@example.com>")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if stored.PrivateKey != nil {
+		t.Error("keyring stored Bob's private key")
+	}
+
+	// Lookup picks the newest key for an address.
+	found, err := keyring.Lookup("ALICE@example.com")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if keyInfo(found).Fingerprint != keyInfo(aliceNew).Fingerprint {
+		t.Errorf("Lookup() returned key %s, want the newest key %s", keyInfo(found).Fingerprint, keyInfo(aliceNew).Fingerprint)
+	}
+	if _, err := keyring.Lookup("carol@example.com"); err == nil {
+		t.Error("Lookup() of an unknown address succeeded")
+	}
+
+	// Remove by key ID, then by address.
+	removed, err := keyring.Remove("0x" + strings.ToLower(keyInfo(aliceNew).KeyID))
+	if err != nil || len(removed) != 1 || removed[0].Fingerprint != keyInfo(aliceNew).Fingerprint {
+		t.Fatalf("Remove(key ID) = %+v, %v, want the newest Alice key", removed, err)
+	}
+	if found, err := keyring.Lookup("alice@example.com"); err != nil || keyInfo(found).Fingerprint != keyInfo(alice).Fingerprint {
+		t.Errorf("Lookup() after Remove() = %v, want the older Alice key", err)
+	}
+	if removed, err := keyring.Remove("bob@example.com"); err != nil || len(removed) != 1 {
+		t.Fatalf("Remove(address) = %+v, %v, want Bob's key", removed, err)
+	}
+	if _, err := keyring.Remove("bob@example.com"); err == nil {
+		t.Error("Remove() of a removed key succeeded")
+	}
+	if listed, err := keyring.List(); err != nil || len(listed) != 1 {
+		t.Errorf("List() = %+v, %v, want one key left", listed, err)
+	}
+}
+
+func TestPGPSign(t *testing.T) {
+	sender := newPGPEntity(t, "Sender", "sender@example.com", time.Now().Add(-time.Hour))
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret",
+		WithProtector(NewPGPProtector(nil, sender)))
+
+	data, err := service.Compose(&Message{
+		Recipients: Recipients{To: []string{"to@example.com"}},
+		Subject:    "Signed",
+		TextBody:   "Signed  text \r\nwith trailing space ",
+		HTMLBody:   "<p>Signed</p>",
+		Sign:       true,
+	})
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("net/mail cannot parse the message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/signed" || params["protocol"] != "application/pgp-signature" || params["micalg"] != "pgp-sha256" {
+		t.Fatalf("Content-Type = %q, want multipart/signed with the PGP protocol and micalg", parsed.Header.Get("Content-Type"))
+	}
+	if parsed.Header.Get("Subject") != "Signed" {
+		t.Errorf("Subject = %q, want it on the outer message", parsed.Header.Get("Subject"))
+	}
+
+	// RFC 1847: the signed entity is every byte between the first delimiter
+	// line and the CRLF preceding the next one.
+	body, err := io.ReadAll(parsed.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delimiter := "--" + params["boundary"]
+	parts := strings.Split(string(body), "\r\n"+delimiter)
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], delimiter+"\r\n") || !strings.HasPrefix(parts[2], "--") {
+		t.Fatalf("multipart/signed does not hold exactly two parts:\n%s", body)
+	}
+	signed := strings.TrimPrefix(parts[0], delimiter+"\r\n")
+	if !strings.HasPrefix(signed, "Content-Type: multipart/mixed") {
+		t.Errorf("signed entity does not start with the original Content-Type:\n%s", signed)
+	}
+	signatureHeader, signature, _ := strings.Cut(strings.TrimPrefix(parts[1], "\r\n"), "\r\n\r\n")
+	if !strings.Contains(signatureHeader, "application/pgp-signature") {
+		t.Fatalf("second part is not a PGP signature:\n%s", parts[1])
+	}
+
+	keyring := openpgp.EntityList{sender}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, strings.NewReader(signed), strings.NewReader(signature), nil); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	tampered := strings.Replace(signed, "Signed", "Forged", 1)
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, strings.NewReader(tampered), strings.NewReader(signature), nil); err == nil {
+		t.Error("signature verifies after the content changed")
+	}
+}
+
+func TestPGPEncrypt(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	sender := newPGPEntity(t, "Sender", "sender@example.com", created)
+	recipient := newPGPEntity(t, "Recipient", "to@example.com", created)
+	keyring := NewKeyring(t.TempDir())
+	if _, err := keyring.Import(bytes.NewReader(armoredKeys(t, false, recipient))); err != nil {
+		t.Fatal(err)
+	}
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret",
+		WithProtector(NewPGPProtector(keyring, sender)))
+
+	data, err := service.Compose(&Message{
+		Recipients: Recipients{To: []string{"Recipient <to@example.com>"}},
+		Subject:    "Confidential",
+		TextBody:   "Quarterly numbers",
+		Sign:       true,
+		Encrypt:    true,
+	})
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("net/mail cannot parse the message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/encrypted" || params["protocol"] != "application/pgp-encrypted" {
+		t.Fatalf("Content-Type = %q, want multipart/encrypted", parsed.Header.Get("Content-Type"))
+	}
+	if strings.Contains(data, "Quarterly numbers") {
+		t.Fatal("message body is readable without decryption")
+	}
+
+	// Both the recipient and the sender, who has an encryption subkey, can
+	// decrypt the message, and the sender's signature verifies.
+	for _, reader := range []*openpgp.Entity{recipient, sender} {
+		block, err := armor.Decode(strings.NewReader(data[strings.Index(data, "-----BEGIN PGP MESSAGE-----"):]))
+		if err != nil {
+			t.Fatalf("failed to decode the armored message: %v", err)
+		}
+		details, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{reader, sender}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s cannot decrypt the message: %v", keyInfo(reader).Identities[0], err)
+		}
+		plaintext, err := io.ReadAll(details.UnverifiedBody)
+		if err != nil {
+			t.Fatalf("failed to read the decrypted message: %v", err)
+		}
+		if !strings.Contains(string(plaintext), "Quarterly numbers") || !strings.HasPrefix(string(plaintext), "Content-Type: multipart/mixed") {
+			t.Errorf("decrypted entity is not the original content:\n%s", plaintext)
+		}
+		if !details.IsSigned || details.SignatureError != nil {
+			t.Errorf("signed = %v, signature error = %v, want a valid signature", details.IsSigned, details.SignatureError)
+		}
+	}
+}
+
+func TestPGPEncryptMissingRecipientKey(t *testing.T) {
+	recipient := newPGPEntity(t, "Recipient", "to@example.com", time.Now().Add(-time.Hour))
+	keyring := NewKeyring(t.TempDir())
+	if _, err := keyring.Import(bytes.NewReader(armoredKeys(t, false, recipient))); err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTP{}
+	host, port := server.start(t)
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone), WithProtector(NewPGPProtector(keyring, nil)))
+
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"to@example.com"}, Cc: []string{"unknown@example.com"}},
+		Subject:    "Confidential",
+		TextBody:   "Hello",
+		Encrypt:    true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown@example.com") {
+		t.Fatalf("Send() error = %v, want one naming unknown@example.com", err)
+	}
+	if messages := server.Messages(); len(messages) != 0 {
+		t.Errorf("server received %d messages, want none", len(messages))
+	}
+}
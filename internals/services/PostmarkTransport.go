@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// DefaultPostmarkEndpoint is the base URL of Postmark's API.
+const DefaultPostmarkEndpoint = "https://api.postmarkapp.com"
+
+// PostmarkTransport sends messages through Postmark's email API. Like
+// SendGrid, Postmark only accepts structured JSON, so the message is taken
+// apart into its recipients, bodies, headers and attachments.
+type PostmarkTransport struct {
+	serverToken   string
+	messageStream string
+	endpoint      string
+	client        *http.Client
+}
+
+// NewPostmarkTransport creates a Postmark transport.
+// Parameters:
+// - serverToken: The server's API token.
+// - messageStream: The message stream to send through; empty uses the server's default transactional stream.
+// - endpoint: The API base URL; empty uses DefaultPostmarkEndpoint.
+func NewPostmarkTransport(serverToken, messageStream, endpoint string) *PostmarkTransport {
+	return &PostmarkTransport{
+		serverToken:   serverToken,
+		messageStream: messageStream,
+		endpoint:      apiEndpoint(endpoint, DefaultPostmarkEndpoint),
+		client:        newAPIClient(),
+	}
+}
+
+// postmarkRequest is the body of a send email request.
+type postmarkRequest struct {
+	From          string               `json:"From"`
+	To            string               `json:"To,omitempty"`
+	Cc            string               `json:"Cc,omitempty"`
+	Bcc           string               `json:"Bcc,omitempty"`
+	ReplyTo       string               `json:"ReplyTo,omitempty"`
+	Subject       string               `json:"Subject"`
+	TextBody      string               `json:"TextBody,omitempty"`
+	HtmlBody      string               `json:"HtmlBody,omitempty"`
+	Headers       []Header             `json:"Headers,omitempty"`
+	Attachments   []postmarkAttachment `json:"Attachments,omitempty"`
+	MessageStream string               `json:"MessageStream,omitempty"`
+}
+
+// postmarkAttachment is an attachment or inline image in a Postmark request.
+type postmarkAttachment struct {
+	Name        string `json:"Name"`
+	Content     string `json:"Content"`
+	ContentType string `json:"ContentType"`
+	ContentID   string `json:"ContentID,omitempty"`
+}
+
+// Deliver sends the message with one send email request.
+// Parameters:
+// - ctx: Cancels the request when done.
+// - from: The envelope sender (unused; Postmark uses the From header).
+// - to: The envelope recipients, including any Bcc addresses.
+// - message: The complete message with CRLF line endings.
+func (t *PostmarkTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	parsed, err := parseAPIMessage(TransportPostmark, message, to)
+	if err != nil {
+		return err
+	}
+
+	payload := postmarkRequest{
+		From:          parsed.From.String(),
+		To:            postmarkAddresses(parsed.To),
+		Cc:            postmarkAddresses(parsed.Cc),
+		Bcc:           postmarkAddresses(parsed.Bcc),
+		ReplyTo:       postmarkAddresses(parsed.ReplyTo),
+		Subject:       parsed.Subject,
+		TextBody:      parsed.Text,
+		HtmlBody:      parsed.HTML,
+		Headers:       parsed.Headers,
+		MessageStream: t.messageStream,
+	}
+	for _, attachment := range parsed.Attachments {
+		contentID := ""
+		if attachment.ContentID != "" {
+			contentID = "cid:" + attachment.ContentID
+		}
+		payload.Attachments = append(payload.Attachments, postmarkAttachment{
+			Name:        attachment.Name,
+			Content:     base64.StdEncoding.EncodeToString(attachment.Content),
+			ContentType: attachment.ContentType,
+			ContentID:   contentID,
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode Postmark request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/email", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Postmark-Server-Token", t.serverToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return doAPIRequest(t.client, TransportPostmark, req)
+}
+
+// postmarkAddresses formats parsed addresses as the comma-separated list Postmark expects.
+func postmarkAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		formatted = append(formatted, address.String())
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPostmarkTransport(t *testing.T) {
+	standIn := newAPIStandIn(t, http.StatusOK, `{"ErrorCode":0,"Message":"OK"}`)
+	envelope, data := composeAPITestMessage(t)
+
+	transport := NewPostmarkTransport("server-token", "outbound", standIn.URL)
+	if err := transport.Deliver(context.Background(), "reports@example.com", envelope, data); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	req := standIn.request(t)
+	if req.Method != http.MethodPost || req.Path != "/email" {
+		t.Errorf("request = %s %s, want POST /email", req.Method, req.Path)
+	}
+	if got := req.Header.Get("X-Postmark-Server-Token"); got != "server-token" {
+		t.Errorf("X-Postmark-Server-Token = %q, want the server token", got)
+	}
+	if req.Header.Get("Accept") != "application/json" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Accept = %q, Content-Type = %q, want application/json", req.Header.Get("Accept"), req.Header.Get("Content-Type"))
+	}
+
+	var payload postmarkRequest
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatalf("request body is not JSON: %v", err)
+	}
+	checks := []struct{ field, got, want string }{
+		{"From", payload.From, `"Reports" <reports@example.com>`},
+		{"To", payload.To, `"Alice" <alice@example.com>`},
+		{"Cc", payload.Cc, "<carol@example.com>"},
+		{"Bcc", payload.Bcc, "<hidden@example.com>"},
+		{"ReplyTo", payload.ReplyTo, "<replies@example.com>"},
+		{"Subject", payload.Subject, "Quarterly report"},
+		{"TextBody", payload.TextBody, "See the report."},
+		{"HtmlBody", payload.HtmlBody, `<p>See the report.</p><img src="cid:logo@example.com">`},
+		{"MessageStream", payload.MessageStream, "outbound"},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s = %q, want %q", check.field, check.got, check.want)
+		}
+	}
+
+	var custom []Header
+	for _, header := range payload.Headers {
+		if header.Name == "X-Campaign" {
+			custom = append(custom, header)
+		}
+		if header.Name == "Bcc" {
+			t.Error("headers repeat the Bcc field")
+		}
+	}
+	if !reflect.DeepEqual(custom, []Header{{Name: "X-Campaign", Value: "q3"}}) {
+		t.Errorf("headers = %+v, want X-Campaign: q3", payload.Headers)
+	}
+
+	if len(payload.Attachments) != 2 {
+		t.Fatalf("request has %d attachments, want 2", len(payload.Attachments))
+	}
+	attachments := map[string]postmarkAttachment{}
+	for _, attachment := range payload.Attachments {
+		attachments[attachment.Name] = attachment
+	}
+	if report := attachments["report.csv"]; report.ContentID != "" || decodeBase64(t, report.Content) != "a,b\r\n1,2\r\n" {
+		t.Errorf("report attachment = %+v", report)
+	}
+	// Postmark matches inline images by the "cid:" reference used in the HTML.
+	if logo := attachments["logo.png"]; logo.ContentID != "cid:logo@example.com" || logo.ContentType != "image/png" ||
+		decodeBase64(t, logo.Content) != string(testInlineImage) {
+		t.Errorf("inline image = %+v, want ContentID cid:logo@example.com", logo)
+	}
+}
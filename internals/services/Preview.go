@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// DescribeMessage writes a human readable summary of a composed message: its
+// header section as it will be sent, followed by an outline of its MIME tree
+// with each part's type, encoding, file name and decoded size.
+// Parameters:
+// - w: The destination, e.g. os.Stdout.
+// - message: The complete message, e.g. as returned by Compose.
+func DescribeMessage(w io.Writer, message string) error {
+	header, body, found := strings.Cut(message, "\r\n\r\n")
+	if !found {
+		return errors.New("message has no header/body separator")
+	}
+	if _, err := io.WriteString(w, strings.ReplaceAll(header, "\r\n", "\n")+"\n\n"); err != nil {
+		return err
+	}
+
+	fields, err := textproto.NewReader(bufio.NewReader(strings.NewReader(header + "\r\n\r\n"))).ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("failed to parse message header: %v", err)
+	}
+	if _, err := fmt.Fprintf(w, "MIME structure (%d bytes):\n", len(message)); err != nil {
+		return err
+	}
+	return describePart(w, fields, []byte(body), 1)
+}
+
+// describePart writes one line for a MIME entity and recurses into multipart bodies.
+// Parameters:
+// - w: The destination.
+// - header: The entity's header fields.
+// - body: The entity's raw, still encoded body.
+// - depth: The nesting level, used for indentation.
+func describePart(w io.Writer, header textproto.MIMEHeader, body []byte, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045 defaults untyped entities to plain text.
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if _, err := fmt.Fprintf(w, "%s%s\n", indent, mediaType); err != nil {
+			return err
+		}
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			// NextRawPart keeps quoted-printable bodies encoded, as they were sent.
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s part: %v", mediaType, err)
+			}
+			partBody, err := io.ReadAll(part)
+			if err != nil {
+				return fmt.Errorf("failed to read %s part: %v", mediaType, err)
+			}
+			if err := describePart(w, part.Header, partBody, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	var details []string
+	if charset := params["charset"]; charset != "" {
+		details = append(details, "charset="+charset)
+	}
+	encoding := strings.ToLower(header.Get("Content-Transfer-Encoding"))
+	if encoding != "" {
+		details = append(details, encoding)
+	}
+	if disposition, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		details = append(details, disposition)
+		if name := dispParams["filename"]; name != "" {
+			details = append(details, fmt.Sprintf("%q", name))
+		}
+	}
+	if id := header.Get("Content-Id"); id != "" {
+		details = append(details, "cid:"+strings.Trim(id, "<>"))
+	}
+	details = append(details, fmt.Sprintf("%d bytes", decodedSize(encoding, body)))
+
+	_, err = fmt.Fprintf(w, "%s%s (%s)\n", indent, mediaType, strings.Join(details, ", "))
+	return err
+}
+
+// decodedSize returns the size of a body after undoing its transfer encoding,
+// or its encoded size when it cannot be decoded.
+// Parameters:
+// - encoding: The lower case Content-Transfer-Encoding.
+// - body: The encoded body.
+func decodedSize(encoding string, body []byte) int {
+	n, err := io.Copy(io.Discard, transferDecoder(encoding, body))
+	if err != nil {
+		return len(body)
+	}
+	return int(n)
+}
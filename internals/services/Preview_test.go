@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDescribeMessage(t *testing.T) {
+	dir := t.TempDir()
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(logo, testInlineImage, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "Übersicht.json")
+	if err := os.WriteFile(report, []byte(strings.Repeat("[1]\n", 100)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	const htmlBody = `<p>Hello</p><img src="cid:logo@example.com">`
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret")
+	data, err := service.Compose(&Message{
+		Recipients:  Recipients{To: []string{"to@example.com"}, Bcc: []string{"hidden@example.com"}},
+		Subject:     "Preview",
+		TextBody:    "Hello",
+		HTMLBody:    htmlBody,
+		Inline:      []InlineResource{{ContentID: "logo@example.com", Path: logo}},
+		Attachments: []string{report},
+	})
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := DescribeMessage(&out, data); err != nil {
+		t.Fatalf("DescribeMessage() error = %v", err)
+	}
+	header, outline, found := strings.Cut(out.String(), "\n\nMIME structure (")
+	if !found {
+		t.Fatalf("output has no MIME structure section:\n%s", out.String())
+	}
+	wantHeader, _, _ := strings.Cut(data, "\r\n\r\n")
+	if header != strings.ReplaceAll(wantHeader, "\r\n", "\n") {
+		t.Errorf("header section = %q, want the message header with LF endings", header)
+	}
+
+	_, tree, _ := strings.Cut(outline, "\n")
+	want := "  multipart/mixed\n" +
+		"    multipart/related\n" +
+		"      multipart/alternative\n" +
+		"        text/plain (charset=UTF-8, quoted-printable, 5 bytes)\n" +
+		fmt.Sprintf("        text/html (charset=UTF-8, quoted-printable, %d bytes)\n", len(htmlBody)) +
+		fmt.Sprintf("      image/png (base64, inline, \"logo.png\", cid:logo@example.com, %d bytes)\n", len(testInlineImage)) +
+		"    application/json (base64, attachment, \"Übersicht.json\", 400 bytes)\n"
+	if tree != want {
+		t.Errorf("MIME tree =\n%s\nwant\n%s", tree, want)
+	}
+
+	if err := DescribeMessage(&out, "no separator"); err == nil {
+		t.Error("DescribeMessage() of a message without a body succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+const (
+	// ProtectionPGP signs and encrypts with PGP/MIME (RFC 3156).
+	ProtectionPGP = "pgp"
+	// ProtectionSMIME signs and encrypts with S/MIME (RFC 8551).
+	ProtectionSMIME = "smime"
+)
+
+// ErrNoProtector is returned when a message asks to be signed or encrypted
+// but the service has no Protector configured.
+var ErrNoProtector = errors.New("signing or encryption requested but not configured")
+
+// ErrEncryptedBcc is returned when a message to be encrypted has Bcc
+// recipients. Encrypted content lists every recipient's key (PGP key IDs,
+// S/MIME RecipientInfos), so each recipient could tell who was blind-copied.
+var ErrEncryptedBcc = errors.New("encrypted messages cannot have Bcc recipients: their keys would be visible to every recipient")
+
+// Protector signs and/or encrypts a built message, wrapping its content in a
+// MIME security structure such as PGP/MIME or S/MIME.
+type Protector interface {
+	// Protect returns the protected message.
+	// Parameters:
+	// - message: The complete message built by the service, with CRLF line endings.
+	// - recipients: The envelope recipients, used to find encryption keys.
+	// - sign: Whether to sign the message.
+	// - encrypt: Whether to encrypt the message.
+	Protect(message string, recipients []string, sign, encrypt bool) (string, error)
+}
+
+// splitEntity splits a built message into the header fields that stay on the
+// outer message (From, To, Subject, ...) and the MIME entity to protect, made
+// of the Content-* fields and the body.
+// Parameters:
+// - message: The complete message with CRLF line endings.
+func splitEntity(message string) (outer, entity string, err error) {
+	header, body, found := strings.Cut(message, "\r\n\r\n")
+	if !found {
+		return "", "", errors.New("message has no header/body separator")
+	}
+
+	var outerFields, entityFields strings.Builder
+	for _, field := range splitHeaderFields(header + "\r\n") {
+		if strings.HasPrefix(strings.ToLower(fieldName(field)), "content-") {
+			entityFields.WriteString(field)
+		} else {
+			outerFields.WriteString(field)
+		}
+	}
+	return outerFields.String(), entityFields.String() + "\r\n" + body, nil
+}
+
+// randomBoundary returns a new random multipart boundary.
+func randomBoundary() string {
+	return multipart.NewWriter(io.Discard).Boundary()
+}
+
+// signedMultipart returns a multipart/signed entity (RFC 1847) holding the
+// signed entity followed by its detached signature.
+// Parameters:
+// - entity: The signed MIME entity, reproduced byte for byte.
+// - protocol: The protocol parameter, e.g. "application/pgp-signature".
+// - micalg: The micalg parameter naming the signature's hash.
+// - signature: The complete signature part: its header fields, a blank line and the CRLF terminated body.
+func signedMultipart(entity, protocol, micalg, signature string) string {
+	boundary := randomBoundary()
+	var b strings.Builder
+	b.WriteString(formatHeader("Content-Type", fmt.Sprintf(
+		`multipart/signed; boundary="%s"; micalg=%s; protocol="%s"`, boundary, micalg, protocol)))
+	b.WriteString("\r\n")
+	// The CRLF before each delimiter belongs to the delimiter, so the signed
+	// entity, including its own final CRLF, is reproduced exactly.
+	fmt.Fprintf(&b, "--%s\r\n%s\r\n--%s\r\n", boundary, entity, boundary)
+	b.WriteString(signature)
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recordingProtector is a Protector that records the recipients it was asked to encrypt to.
+type recordingProtector struct {
+	recipients []string
+}
+
+func (p *recordingProtector) Protect(message string, recipients []string, sign, encrypt bool) (string, error) {
+	p.recipients = recipients
+	return message, nil
+}
+
+func TestEncryptedMessageRejectsBcc(t *testing.T) {
+	protector := &recordingProtector{}
+	server := &fakeSMTP{}
+	host, port := server.start(t)
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone), WithProtector(protector))
+
+	msg := &Message{
+		Recipients: Recipients{To: []string{"to@example.com"}, Bcc: []string{"hidden@example.com"}},
+		Subject:    "Confidential",
+		TextBody:   "Hello",
+		Encrypt:    true,
+	}
+	if err := service.Send(context.Background(), msg); !errors.Is(err, ErrEncryptedBcc) {
+		t.Fatalf("Send() error = %v, want %v", err, ErrEncryptedBcc)
+	}
+	if protector.recipients != nil || len(server.Messages()) != 0 {
+		t.Fatal("message was encrypted or sent despite its Bcc recipients")
+	}
+
+	// Signing alone reveals nothing about the recipients.
+	msg.Encrypt, msg.Sign = false, true
+	if err := service.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(server.Messages()) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(server.Messages()))
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+// Recipients groups the addresses an email is delivered to.
+// Bcc addresses are only used for the SMTP envelope and never appear in headers.
+type Recipients struct {
+	To      []string // Primary recipients, written to the To header.
+	Cc      []string // Carbon-copy recipients, written to the Cc header.
+	Bcc     []string // Blind carbon-copy recipients, envelope only.
+	ReplyTo []string // Addresses replies should go to, written to the Reply-To header.
+}
+
+// Envelope returns the bare addresses of every recipient for the SMTP RCPT TO commands,
+// skipping duplicates so nobody receives the same message twice.
+func (r Recipients) Envelope() []string {
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, list := range [][]string{r.To, r.Cc, r.Bcc} {
+		for _, address := range list {
+			bare := envelopeAddress(address)
+			if seen[bare] {
+				continue
+			}
+			seen[bare] = true
+			addresses = append(addresses, bare)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/textproto"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry; it doubles with every attempt.
+	retryBaseDelay = 2 * time.Second
+	// DefaultRetryMaxDelay caps the delay between two attempts.
+	DefaultRetryMaxDelay = 60 * time.Second
+)
+
+// isTransient reports whether a failed send may succeed when retried:
+// 4xx SMTP replies (e.g. greylisting), rate limited or failing HTTP APIs (429, 5xx),
+// timeouts and network errors are transient, 5xx replies, TLS failures and
+// local errors are permanent. Cancellation is never retried.
+// When several recipients were rejected, the send is only retried if every
+// rejection was transient.
+// Parameters:
+// - err: The error returned by the send.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !isTransient(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var smtpErr *SMTPError
+	if errors.As(err, &smtpErr) && smtpErr.Code != 0 {
+		return smtpErr.Temporary()
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+	// TLS alerts and certificate failures arrive as network errors, but a
+	// retry would meet the same certificate or configuration.
+	if errors.Is(err, ErrTLS) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryDelay returns the jittered exponential backoff before the given retry.
+// The delay is drawn from [d/2, d) where d doubles per attempt up to the ceiling.
+// Parameters:
+// - attempt: The number of attempts made so far, starting at 1.
+func (es *DhanuEmailService) retryDelay(attempt int) time.Duration {
+	delay := es.maxDelay
+	if shift := attempt - 1; shift < 30 {
+		if d := retryBaseDelay << shift; d < delay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
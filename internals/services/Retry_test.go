@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	remoteAlert := &net.OpError{Op: "remote error", Net: "tcp", Err: errors.New("tls: bad certificate")}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "greylisted", err: newSMTPError(ErrRecipientRejected, &textproto.Error{Code: 451, Msg: "4.7.1 try later"}), want: true},
+		{name: "mailbox unknown", err: newSMTPError(ErrRecipientRejected, &textproto.Error{Code: 550, Msg: "5.1.1 no such user"}), want: false},
+		{name: "connection refused", err: newSMTPError(ErrConnection, refused), want: true},
+		{name: "timeout", err: fmt.Errorf("%w: %w", ErrTimeout, newSMTPError(ErrConnection, refused)), want: true},
+		{name: "TLS alert", err: newSMTPError(ErrTLS, remoteAlert), want: false},
+		{name: "STARTTLS not offered", err: newSMTPError(ErrTLS, errors.New("server does not support STARTTLS")), want: false},
+		{name: "cancelled", err: fmt.Errorf("email sending aborted: %w", context.Canceled), want: false},
+		{name: "local error", err: errors.New("sender address is not configured"), want: false},
+		{
+			name: "all recipients greylisted",
+			err: errors.Join(
+				newRecipientError("a@example.com", &textproto.Error{Code: 450, Msg: "busy"}),
+				newRecipientError("b@example.com", &textproto.Error{Code: 451, Msg: "busy"}),
+			),
+			want: true,
+		},
+		{
+			name: "one recipient rejected",
+			err: errors.Join(
+				newRecipientError("a@example.com", &textproto.Error{Code: 450, Msg: "busy"}),
+				newRecipientError("b@example.com", &textproto.Error{Code: 550, Msg: "unknown"}),
+			),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		replies      []string // RCPT replies for successive attempts; later attempts get the last one.
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "greylisted then accepted", retries: 3, replies: []string{"451 4.7.1 try later", ""}, wantAttempts: 2},
+		{name: "permanent rejection", retries: 3, replies: []string{"550 5.1.1 no such user"}, wantAttempts: 1, wantErr: ErrRecipientRejected},
+		{name: "retries exhausted", retries: 2, replies: []string{"451 4.7.1 try later"}, wantAttempts: 3, wantErr: ErrRecipientRejected},
+		{name: "no retries", retries: 0, replies: []string{"451 4.7.1 try later", ""}, wantAttempts: 1, wantErr: ErrRecipientRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := &fakeSMTP{replies: func(command string) string {
+				if !strings.HasPrefix(command, "RCPT") {
+					return ""
+				}
+				attempt := int(attempts.Add(1))
+				return tt.replies[min(attempt, len(tt.replies))-1]
+			}}
+			host, port := server.start(t)
+			service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+				WithSecurity(SecurityNone),
+				WithRetry(tt.retries, 10*time.Millisecond),
+			)
+
+			err := service.Send(context.Background(), &Message{
+				Recipients: Recipients{To: []string{"rcpt@example.com"}},
+				Subject:    "Retry test",
+				TextBody:   "Hello",
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Send() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("Send() error = %v", err)
+			}
+			if got := int(attempts.Load()); got != tt.wantAttempts {
+				t.Errorf("server saw %d attempts, want %d", got, tt.wantAttempts)
+			}
+			wantMessages := 0
+			if tt.wantErr == nil {
+				wantMessages = 1
+			}
+			if messages := server.Messages(); len(messages) != wantMessages {
+				t.Errorf("server received %d messages, want %d", len(messages), wantMessages)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsWhenContextIsCancelled(t *testing.T) {
+	var attempts atomic.Int32
+	server := &fakeSMTP{replies: func(command string) string {
+		if strings.HasPrefix(command, "RCPT") {
+			attempts.Add(1)
+			return "451 4.7.1 try later"
+		}
+		return ""
+	}}
+	host, port := server.start(t)
+	// The first retry would wait at least a second.
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone),
+		WithRetry(5, time.Minute),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	err := service.Send(ctx, &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "Retry test",
+		TextBody:   "Hello",
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Send() returned after %s, want the backoff cut short", elapsed)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("server saw %d attempts, want 1", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	service := &DhanuEmailService{maxDelay: 10 * time.Second}
+	for attempt, ceiling := range map[int]time.Duration{
+		1:  2 * time.Second,
+		2:  4 * time.Second,
+		3:  8 * time.Second,
+		4:  10 * time.Second,
+		40: 10 * time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			if delay := service.retryDelay(attempt); delay < ceiling/2 || delay > ceiling {
+				t.Fatalf("retryDelay(%d) = %s, want between %s and %s", attempt, delay, ceiling/2, ceiling)
+			}
+		}
+	}
+}
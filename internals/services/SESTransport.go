@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SESTransport sends messages through the Amazon SES v2 SendEmail API as raw
+// MIME, so DKIM, PGP/MIME and S/MIME signatures survive unchanged. Requests
+// are signed with AWS Signature Version 4.
+type SESTransport struct {
+	region   string
+	creds    AWSCredentials
+	endpoint string
+	client   *http.Client
+}
+
+// NewSESTransport creates an Amazon SES transport.
+// Parameters:
+// - region: The AWS region the sending identity is verified in, e.g. "eu-west-1".
+// - creds: The credentials of an IAM identity allowed to call ses:SendEmail.
+// - endpoint: The API base URL; empty uses "https://email.<region>.amazonaws.com".
+func NewSESTransport(region string, creds AWSCredentials, endpoint string) *SESTransport {
+	return &SESTransport{
+		region:   region,
+		creds:    creds,
+		endpoint: apiEndpoint(endpoint, "https://email."+region+".amazonaws.com"),
+		client:   newAPIClient(),
+	}
+}
+
+// sesRequest is the body of a SendEmail request with raw content.
+type sesRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Raw struct {
+			Data string `json:"Data"`
+		} `json:"Raw"`
+	} `json:"Content"`
+}
+
+// Deliver sends the message with one SendEmail request.
+// Parameters:
+// - ctx: Cancels the request when done.
+// - from: The envelope sender, which must be a verified SES identity.
+// - to: The envelope recipients, including any Bcc addresses.
+// - message: The complete message with CRLF line endings.
+func (t *SESTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	if t.region == "" {
+		return fmt.Errorf("the %s transport requires an AWS region", TransportSES)
+	}
+	if t.creds.AccessKeyID == "" || t.creds.SecretAccessKey == "" {
+		return fmt.Errorf("the %s transport requires AWS credentials", TransportSES)
+	}
+
+	var payload sesRequest
+	payload.FromEmailAddress = from
+	// The destination is the envelope; the headers in the raw message are sent unchanged.
+	for _, recipient := range to {
+		payload.Destination.ToAddresses = append(payload.Destination.ToAddresses, envelopeAddress(recipient))
+	}
+	payload.Content.Raw.Data = base64.StdEncoding.EncodeToString([]byte(message))
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode SES request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signAWSv4(req, body, t.creds, t.region, "ses", time.Now())
+	return doAPIRequest(t.client, TransportSES, req)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSESTransport(t *testing.T) {
+	standIn := newAPIStandIn(t, http.StatusOK, `{"MessageId":"0100018f"}`)
+	envelope, data := composeAPITestMessage(t)
+
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session-token"}
+	transport := NewSESTransport("eu-west-1", creds, standIn.URL)
+	if err := transport.Deliver(context.Background(), "reports@example.com", envelope, data); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	req := standIn.request(t)
+	if req.Method != http.MethodPost || req.Path != "/v2/email/outbound-emails" {
+		t.Errorf("request = %s %s, want POST /v2/email/outbound-emails", req.Method, req.Path)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q, want the session token", got)
+	}
+
+	// Sign the request as received, at the time it claims, and compare: the
+	// signature must cover the body, host and headers that reached the server.
+	signedAt, err := time.Parse("20060102T150405Z", req.Header.Get("X-Amz-Date"))
+	if err != nil {
+		t.Fatalf("invalid X-Amz-Date %q: %v", req.Header.Get("X-Amz-Date"), err)
+	}
+	check, err := http.NewRequest(req.Method, standIn.URL+req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Header.Set("Content-Type", req.Header.Get("Content-Type"))
+	signAWSv4(check, req.Body, creds, "eu-west-1", "ses", signedAt)
+	wantAuth := check.Header.Get("Authorization")
+	if got := req.Header.Get("Authorization"); got != wantAuth {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, wantAuth)
+	}
+	wantPrefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/" + signedAt.Format("20060102") +
+		"/eu-west-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, Signature="
+	if got := req.Header.Get("Authorization"); len(got) != len(wantPrefix)+64 || got[:len(wantPrefix)] != wantPrefix {
+		t.Errorf("Authorization = %q, want the prefix %q and a 64-digit signature", got, wantPrefix)
+	}
+
+	var payload sesRequest
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatalf("request body is not JSON: %v", err)
+	}
+	if payload.FromEmailAddress != "reports@example.com" {
+		t.Errorf("FromEmailAddress = %q", payload.FromEmailAddress)
+	}
+	// SES takes the destination from the envelope, so Bcc recipients are listed here only.
+	wantTo := []string{"alice@example.com", "carol@example.com", "hidden@example.com"}
+	if !reflect.DeepEqual(payload.Destination.ToAddresses, wantTo) {
+		t.Errorf("ToAddresses = %q, want %q", payload.Destination.ToAddresses, wantTo)
+	}
+	if decodeBase64(t, payload.Content.Raw.Data) != data {
+		t.Error("raw content differs from the composed message")
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+)
+
+// pkcs7Mu serialises this package's encryptions, each of which sets the pkcs7
+// library's global ContentEncryptionAlgorithm before calling pkcs7.Encrypt.
+// It does not protect against other packages that change the global.
+var pkcs7Mu sync.Mutex
+
+// oidEmailAddress is the PKCS#9 emailAddress attribute found in older certificate subjects.
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// CertStore is a directory of recipients' PEM encoded X.509 certificates
+// (*.pem, *.crt or *.cer files), used to encrypt S/MIME messages.
+type CertStore struct {
+	dir string
+}
+
+// NewCertStore opens the certificate directory.
+// Parameters:
+// - dir: The directory holding the certificates.
+func NewCertStore(dir string) *CertStore {
+	return &CertStore{dir: dir}
+}
+
+// Lookup returns the newest valid RSA certificate issued to an address.
+// Parameters:
+// - address: The recipient address, optionally in "Name <user@example.com>" form.
+func (s *CertStore) Lookup(address string) (*x509.Certificate, error) {
+	email := envelopeAddress(address)
+	certs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var found *x509.Certificate
+	for _, cert := range certs {
+		if !certHasEmail(cert, email) || now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			continue
+		}
+		// Key transport to the recipient is only implemented for RSA.
+		if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+			continue
+		}
+		if found == nil || cert.NotBefore.After(found.NotBefore) {
+			found = cert
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no usable S/MIME certificate for %s", email)
+	}
+	return found, nil
+}
+
+// load reads every certificate in the store directory.
+func (s *CertStore) load() ([]*x509.Certificate, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".pem", ".crt", ".cer":
+		default:
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate %s: %v", path, err)
+		}
+		certs = append(certs, parsed...)
+	}
+	return certs, nil
+}
+
+// parseCertificates parses every CERTIFICATE block of PEM data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// certHasEmail reports whether a certificate was issued to an address, either
+// in its subject alternative names or its subject.
+func certHasEmail(cert *x509.Certificate, email string) bool {
+	for _, address := range cert.EmailAddresses {
+		if strings.EqualFold(address, email) {
+			return true
+		}
+	}
+	for _, name := range cert.Subject.Names {
+		if value, ok := name.Value.(string); ok && name.Type.Equal(oidEmailAddress) && strings.EqualFold(value, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// SMIMEProtector signs and encrypts messages as S/MIME (RFC 8551).
+type SMIMEProtector struct {
+	certs  *CertStore
+	cert   *x509.Certificate
+	chain  []*x509.Certificate
+	signer crypto.PrivateKey
+}
+
+// NewSMIMEProtector creates an S/MIME protector.
+// Parameters:
+// - certs: The recipients' certificates; required to encrypt.
+// - signer: The signing certificate, its chain and private key, e.g. from tls.LoadX509KeyPair; required to sign.
+// When the signer holds an RSA key, messages are also encrypted to it so the sender can read them.
+func NewSMIMEProtector(certs *CertStore, signer *tls.Certificate) (*SMIMEProtector, error) {
+	p := &SMIMEProtector{certs: certs}
+	if signer != nil {
+		if len(signer.Certificate) == 0 {
+			return nil, errors.New("S/MIME signing certificate is empty")
+		}
+		parsed := make([]*x509.Certificate, 0, len(signer.Certificate))
+		for _, der := range signer.Certificate {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse S/MIME certificate: %v", err)
+			}
+			parsed = append(parsed, cert)
+		}
+		p.cert, p.chain, p.signer = parsed[0], parsed[1:], signer.PrivateKey
+	}
+	return p, nil
+}
+
+// Protect wraps the message in multipart/signed and/or application/pkcs7-mime.
+// When both are requested the message is signed first and the signed entity
+// is encrypted, so the signature is hidden too.
+// Parameters:
+// - message: The complete message with CRLF line endings.
+// - recipients: The recipients to encrypt to; each gets a RecipientInfo that every recipient can see.
+// - sign: Whether to sign the message.
+// - encrypt: Whether to encrypt the message.
+func (p *SMIMEProtector) Protect(message string, recipients []string, sign, encrypt bool) (string, error) {
+	if !sign && !encrypt {
+		return message, nil
+	}
+	outer, entity, err := splitEntity(message)
+	if err != nil {
+		return "", err
+	}
+	if sign {
+		if entity, err = p.sign(entity); err != nil {
+			return "", err
+		}
+	}
+	if encrypt {
+		if entity, err = p.encrypt(entity, recipients); err != nil {
+			return "", err
+		}
+	}
+	return outer + entity, nil
+}
+
+// sign returns the entity wrapped in multipart/signed with a detached PKCS#7 signature.
+func (p *SMIMEProtector) sign(entity string) (string, error) {
+	if p.cert == nil {
+		return "", errors.New("S/MIME signing requested but no certificate is configured")
+	}
+	signedData, err := pkcs7.NewSignedData([]byte(entity))
+	if err != nil {
+		return "", fmt.Errorf("S/MIME signing failed: %v", err)
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := signedData.AddSignerChain(p.cert, p.signer, p.chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return "", fmt.Errorf("S/MIME signing failed: %v", err)
+	}
+	signedData.Detach()
+	signature, err := signedData.Finish()
+	if err != nil {
+		return "", fmt.Errorf("S/MIME signing failed: %v", err)
+	}
+
+	var part strings.Builder
+	part.WriteString("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n")
+	part.WriteString("Content-Transfer-Encoding: base64\r\n")
+	part.WriteString("Content-Disposition: attachment; filename=\"smime.p7s\"\r\n")
+	part.WriteString("Content-Description: S/MIME Cryptographic Signature\r\n\r\n")
+	if err := writeBase64(&part, bytes.NewReader(signature)); err != nil {
+		return "", err
+	}
+	return signedMultipart(entity, "application/pkcs7-signature", "sha-256", part.String()), nil
+}
+
+// encrypt returns the entity as application/pkcs7-mime enveloped data.
+func (p *SMIMEProtector) encrypt(entity string, recipients []string) (string, error) {
+	if p.certs == nil {
+		return "", errors.New("S/MIME encryption requested but no certificate directory is configured")
+	}
+	var certs []*x509.Certificate
+	var missing []error
+	for _, recipient := range recipients {
+		cert, err := p.certs.Lookup(recipient)
+		if err != nil {
+			missing = append(missing, err)
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	if len(missing) > 0 {
+		return "", errors.Join(missing...)
+	}
+	if p.cert != nil {
+		if _, ok := p.cert.PublicKey.(*rsa.PublicKey); ok {
+			certs = append(certs, p.cert)
+		}
+	}
+
+	// The library defaults to DES; AES-256-CBC is what Outlook and every
+	// current mail client expect in S/MIME enveloped data.
+	pkcs7Mu.Lock()
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+	enveloped, err := pkcs7.Encrypt([]byte(entity), certs)
+	pkcs7Mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("S/MIME encryption failed: %v", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name=\"smime.p7m\"\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	b.WriteString("Content-Disposition: attachment; filename=\"smime.p7m\"\r\n")
+	b.WriteString("Content-Description: S/MIME Encrypted Message\r\n\r\n")
+	if err := writeBase64(&b, bytes.NewReader(enveloped)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+)
+
+// newRecipientCertificate creates an RSA certificate issued to email and
+// stores it in dir, as a recipient's certificate in the certificate directory.
+func newRecipientCertificate(t *testing.T, dir, email string) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, email+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestSMIMEEncrypt(t *testing.T) {
+	dir := t.TempDir()
+	toCert, toKey := newRecipientCertificate(t, dir, "to@example.com")
+	ccCert, ccKey := newRecipientCertificate(t, dir, "cc@example.com")
+	newRecipientCertificate(t, dir, "hidden@example.com")
+
+	protector, err := NewSMIMEProtector(NewCertStore(dir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret", WithProtector(protector))
+	newMessage := func() *Message {
+		return &Message{
+			Recipients: Recipients{To: []string{"to@example.com"}, Cc: []string{"cc@example.com"}},
+			Subject:    "Confidential",
+			TextBody:   "Quarterly numbers",
+			Encrypt:    true,
+		}
+	}
+
+	msg := newMessage()
+	msg.Bcc = []string{"hidden@example.com"}
+	if _, err := service.Compose(msg); !errors.Is(err, ErrEncryptedBcc) {
+		t.Fatalf("Compose() with Bcc error = %v, want %v", err, ErrEncryptedBcc)
+	}
+
+	// Encrypt concurrently, as a pooled batch send would.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := service.Compose(newMessage())
+			if err != nil {
+				t.Errorf("Compose() error = %v", err)
+				return
+			}
+			parsed, err := mail.ReadMessage(strings.NewReader(data))
+			if err != nil {
+				t.Errorf("net/mail cannot parse the message: %v", err)
+				return
+			}
+			if contentType := parsed.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/pkcs7-mime") {
+				t.Errorf("Content-Type = %q, want application/pkcs7-mime", contentType)
+				return
+			}
+			der, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, parsed.Body))
+			if err != nil {
+				t.Errorf("failed to decode the enveloped data: %v", err)
+				return
+			}
+			enveloped, err := pkcs7.Parse(der)
+			if err != nil {
+				t.Errorf("failed to parse the enveloped data: %v", err)
+				return
+			}
+			for _, recipient := range []struct {
+				cert *x509.Certificate
+				key  *rsa.PrivateKey
+			}{{toCert, toKey}, {ccCert, ccKey}} {
+				entity, err := enveloped.Decrypt(recipient.cert, recipient.key)
+				if err != nil {
+					t.Errorf("%s cannot decrypt the message: %v", recipient.cert.EmailAddresses[0], err)
+					continue
+				}
+				if !strings.Contains(string(entity), "Quarterly numbers") {
+					t.Errorf("decrypted entity is missing the body:\n%s", entity)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// DSN keywords (RFC 3461). The NOTIFY keywords say when a report is sent for
+// a recipient; the RET keywords say how much of the message a failure report
+// includes.
+const (
+	DSNSuccess        = "SUCCESS"
+	DSNFailure        = "FAILURE"
+	DSNDelay          = "DELAY"
+	DSNNever          = "NEVER"
+	DSNReturnFull     = "FULL"
+	DSNReturnHeaders  = "HDRS"
+	dsnNotifyKeywords = DSNSuccess + " " + DSNFailure + " " + DSNDelay + " " + DSNNever
+)
+
+// DSNOptions request delivery status notifications. They are only sent to
+// servers that advertise the DSN extension; other servers use their defaults.
+type DSNOptions struct {
+	Notify []string // Any of DSNSuccess, DSNFailure and DSNDelay, or DSNNever alone; empty leaves it to the server.
+	Return string   // DSNReturnFull or DSNReturnHeaders; empty leaves it to the server.
+}
+
+// ParseDSN builds DSN options from keywords such as "failure", "delay" and
+// "hdrs", ignoring case. The NOTIFY and RET keywords don't overlap, so both
+// are given in one list.
+// Parameters:
+// - keywords: Any of "success", "failure", "delay" or "never", plus optionally "full" or "hdrs".
+func ParseDSN(keywords []string) (DSNOptions, error) {
+	var dsn DSNOptions
+	for _, keyword := range keywords {
+		keyword = strings.ToUpper(strings.TrimSpace(keyword))
+		switch {
+		case keyword == DSNReturnFull || keyword == DSNReturnHeaders:
+			if dsn.Return != "" && dsn.Return != keyword {
+				return DSNOptions{}, fmt.Errorf("DSN options %q and %q are mutually exclusive", DSNReturnFull, DSNReturnHeaders)
+			}
+			dsn.Return = keyword
+		case strings.Contains(" "+dsnNotifyKeywords+" ", " "+keyword+" "):
+			dsn.Notify = append(dsn.Notify, keyword)
+		default:
+			return DSNOptions{}, fmt.Errorf("unknown DSN option %q; use success, failure, delay, never, full or hdrs", keyword)
+		}
+	}
+	for _, keyword := range dsn.Notify {
+		if keyword == DSNNever && len(dsn.Notify) > 1 {
+			return DSNOptions{}, fmt.Errorf("DSN option %q cannot be combined with other notifications", DSNNever)
+		}
+	}
+	return dsn, nil
+}
+
+// sizeLimit returns the maximum message size the server advertised with the
+// SIZE extension (RFC 1870), or zero when it advertised no fixed limit.
+// Parameters:
+// - client: The connected SMTP client.
+func sizeLimit(client *smtp.Client) int64 {
+	ok, param := client.Extension("SIZE")
+	if !ok {
+		return 0
+	}
+	limit, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64)
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// messageInfo is what the SMTP transaction needs to know about a message
+// before it is written.
+type messageInfo struct {
+	size       int64 // The size in bytes, or a lower bound of it; zero if unknown.
+	eightBit   bool  // The body contains bytes outside ASCII (8BITMIME).
+	utf8Header bool  // The header section contains UTF-8 (SMTPUTF8).
+}
+
+// unknownMessageInfo describes a message whose content is not known before it
+// is written, so every extension the server offers is declared.
+var unknownMessageInfo = messageInfo{eightBit: true, utf8Header: true}
+
+// rawMessageInfo describes a message held in memory.
+// Parameters:
+// - message: The complete message with CRLF line endings.
+func rawMessageInfo(message string) messageInfo {
+	header, body, _ := strings.Cut(message, "\r\n\r\n")
+	return messageInfo{size: int64(len(message)), eightBit: !isASCII(body), utf8Header: !isASCII(header)}
+}
+
+// countingWriter counts the bytes written to it and discards them.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// sendEnvelope sends MAIL FROM and a RCPT TO for every recipient, declaring
+// the message size and DSN parameters the server supports, and 8BITMIME and
+// SMTPUTF8 when the message needs them. With PIPELINING all commands go out
+// before any reply is read. Every recipient is tried so that all rejected
+// addresses are reported together.
+// Parameters:
+// - client: The connected, secured and authenticated SMTP client.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - info: What is known about the message before it is written.
+func (t *smtpTransport) sendEnvelope(client *smtp.Client, from string, to []string, info messageInfo) error {
+	// Internationalized addresses can only be sent to servers that support SMTPUTF8 (RFC 6531).
+	utf8, _ := client.Extension("SMTPUTF8")
+	if containsControl(from) {
+		return newSMTPError(ErrSenderRejected, fmt.Errorf("sender address %q contains control characters", from))
+	}
+	if !utf8 && !isASCII(from) {
+		return newSMTPError(ErrSenderRejected, fmt.Errorf("server does not support SMTPUTF8, required for %q", from))
+	}
+	needsUTF8 := info.utf8Header || !isASCII(from)
+	var invalid []error
+	for _, recipient := range to {
+		if containsControl(recipient) {
+			invalid = append(invalid, newRecipientError(recipient, errors.New("address contains control characters")))
+		} else if !isASCII(envelopeAddress(recipient)) {
+			needsUTF8 = true
+			if !utf8 {
+				invalid = append(invalid, newRecipientError(recipient, errors.New("server does not support SMTPUTF8, required for this address")))
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.Join(invalid...)
+	}
+
+	mail := "MAIL FROM:<" + from + ">"
+	if ok, _ := client.Extension("8BITMIME"); ok && info.eightBit {
+		mail += " BODY=8BITMIME"
+	}
+	if utf8 && needsUTF8 {
+		mail += " SMTPUTF8"
+	}
+	if ok, _ := client.Extension("SIZE"); ok && info.size > 0 {
+		mail += " SIZE=" + strconv.FormatInt(info.size, 10)
+	}
+	notify := ""
+	if ok, _ := client.Extension("DSN"); ok {
+		if t.dsn.Return != "" {
+			mail += " RET=" + t.dsn.Return
+		}
+		if len(t.dsn.Notify) > 0 {
+			notify = " NOTIFY=" + strings.Join(t.dsn.Notify, ",")
+		}
+	}
+	commands := []string{mail}
+	for _, recipient := range to {
+		commands = append(commands, "RCPT TO:<"+envelopeAddress(recipient)+">"+notify)
+	}
+
+	replies, err := t.exchange(client, commands)
+	if err != nil {
+		return newSMTPError(ErrConnection, err)
+	}
+	if replies[0] != nil {
+		return newSMTPError(ErrSenderRejected, replies[0])
+	}
+	var rejected []error
+	for i, recipient := range to {
+		if replies[i+1] != nil {
+			rejected = append(rejected, newRecipientError(recipient, replies[i+1]))
+		}
+	}
+	if len(rejected) > 0 {
+		client.Reset()
+		return errors.Join(rejected...)
+	}
+	return nil
+}
+
+// exchange sends MAIL followed by RCPT commands and returns one error per
+// command, nil where the server accepted it: MAIL expects 250 and RCPT 250 or
+// 251. Without PIPELINING each command waits for its reply, and the rest are
+// not sent once the first one fails.
+// Parameters:
+// - client: The connected SMTP client.
+// - commands: The MAIL command line, then the RCPT ones, without CRLF.
+// Returns an error only when the connection failed.
+func (t *smtpTransport) exchange(client *smtp.Client, commands []string) ([]error, error) {
+	replies := make([]error, len(commands))
+	pipelining, _ := client.Extension("PIPELINING")
+	if pipelining {
+		for _, command := range commands {
+			client.Text.W.WriteString(command + "\r\n")
+		}
+		if err := client.Text.W.Flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, command := range commands {
+		if !pipelining {
+			if i > 0 && replies[0] != nil {
+				break
+			}
+			client.Text.W.WriteString(command + "\r\n")
+			if err := client.Text.W.Flush(); err != nil {
+				return nil, err
+			}
+		}
+		expect := 250
+		if i > 0 {
+			// 251 "user not local; will forward" also accepts the recipient.
+			expect = 25
+		}
+		_, _, err := client.Text.ReadResponse(expect)
+		var protoErr *textproto.Error
+		if err != nil && !errors.As(err, &protoErr) {
+			return nil, err
+		}
+		replies[i] = err
+	}
+	return replies, nil
+}
+
+// isASCII reports whether s consists of ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSizeDeclaration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.bin")
+	if err := os.WriteFile(path, []byte(strings.Repeat("report data ", 5000)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	msg := &Message{
+		Recipients:  Recipients{To: []string{"rcpt@example.com"}},
+		Subject:     "Size",
+		TextBody:    "Totals: a=1 " + strings.Repeat("long line ", 50),
+		HTMLBody:    "<p>Totals</p>",
+		Attachments: []string{path},
+	}
+	sizeParam := regexp.MustCompile(` SIZE=(\d+)`)
+
+	t.Run("streamed", func(t *testing.T) {
+		server := &fakeSMTP{extensions: []string{"SIZE 10000000"}}
+		host, port := server.start(t)
+		service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+		if err := service.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		sent := server.Messages()[0]
+		match := sizeParam.FindStringSubmatch(sent.Mail)
+		if match == nil {
+			t.Fatalf("MAIL FROM declares no size: %q", sent.Mail)
+		}
+		// The estimate leaves out part headers and boundaries only.
+		declared, _ := strconv.Atoi(match[1])
+		if declared > len(sent.Data) || declared < len(sent.Data)-1024 {
+			t.Errorf("declared SIZE=%d for a %d-byte message", declared, len(sent.Data))
+		}
+	})
+
+	t.Run("in memory", func(t *testing.T) {
+		server := &fakeSMTP{extensions: []string{"SIZE 10000000"}}
+		host, port := server.start(t)
+		service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+		data, err := service.Compose(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.SendRaw(context.Background(), msg.Envelope(), data); err != nil {
+			t.Fatalf("SendRaw() error = %v", err)
+		}
+		sent := server.Messages()[0]
+		if want := " SIZE=" + strconv.Itoa(len(data)); !strings.Contains(sent.Mail, want) {
+			t.Errorf("MAIL FROM = %q, want %q", sent.Mail, want)
+		}
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		server := &fakeSMTP{extensions: []string{"SIZE 50000"}}
+		host, port := server.start(t)
+		service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+		if err := service.Send(context.Background(), msg); !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("Send() error = %v, want %v", err, ErrMessageTooLarge)
+		}
+		for _, command := range server.Commands() {
+			if strings.HasPrefix(command, "MAIL") {
+				t.Fatalf("oversized message was offered to the server: %q", command)
+			}
+		}
+	})
+}
+
+func TestEnvelopeExtensions(t *testing.T) {
+	ascii := &Message{Recipients: Recipients{To: []string{"rcpt@example.com"}}, Subject: "Grüße", TextBody: "Grüße, नमस्ते"}
+	international := &Message{Recipients: Recipients{To: []string{"用户@例子.广告"}}, Subject: "Hello", TextBody: "Hello"}
+	raw8bit := "From: sender@example.com\r\nTo: rcpt@example.com\r\nSubject: Hello\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\nGrüße\r\n"
+	rawUTF8Header := "From: sender@example.com\r\nTo: rcpt@example.com\r\nSubject: Grüße\r\n\r\nHello\r\n"
+
+	tests := []struct {
+		name     string
+		msg      *Message // Streamed with Send when set.
+		raw      string   // Sent with SendRaw otherwise.
+		want8BIT bool
+		wantUTF8 bool
+	}{
+		// Text is quoted-printable and headers RFC 2047 encoded, so the message is plain ASCII.
+		{name: "encoded message", msg: ascii},
+		{name: "internationalized recipient", msg: international, wantUTF8: true},
+		{name: "8-bit body", raw: raw8bit, want8BIT: true},
+		{name: "UTF-8 header", raw: rawUTF8Header, wantUTF8: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTP{extensions: []string{"8BITMIME", "SMTPUTF8"}}
+			host, port := server.start(t)
+			service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+
+			var err error
+			if tt.msg != nil {
+				err = service.Send(context.Background(), tt.msg)
+			} else {
+				err = service.SendRaw(context.Background(), []string{"rcpt@example.com"}, tt.raw)
+			}
+			if err != nil {
+				t.Fatalf("send error = %v", err)
+			}
+			mail := server.Messages()[0].Mail
+			if got := strings.Contains(mail, " BODY=8BITMIME"); got != tt.want8BIT {
+				t.Errorf("MAIL FROM = %q, want BODY=8BITMIME %v", mail, tt.want8BIT)
+			}
+			if got := strings.Contains(mail, " SMTPUTF8"); got != tt.wantUTF8 {
+				t.Errorf("MAIL FROM = %q, want SMTPUTF8 %v", mail, tt.wantUTF8)
+			}
+		})
+	}
+
+	t.Run("internationalized recipient without SMTPUTF8", func(t *testing.T) {
+		server := &fakeSMTP{extensions: []string{"8BITMIME"}}
+		host, port := server.start(t)
+		service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+		if err := service.Send(context.Background(), international); !errors.Is(err, ErrRecipientRejected) {
+			t.Fatalf("Send() error = %v, want %v", err, ErrRecipientRejected)
+		}
+		if len(server.Messages()) != 0 {
+			t.Fatal("message was sent without SMTPUTF8")
+		}
+	})
+}
+
+func TestRecipientForwarded(t *testing.T) {
+	for name, extensions := range map[string][]string{"one at a time": nil, "pipelined": {"PIPELINING"}} {
+		t.Run(name, func(t *testing.T) {
+			server := &fakeSMTP{extensions: extensions, replies: func(command string) string {
+				if strings.Contains(command, "<moved@example.com>") {
+					return "251 2.1.5 user not local; will forward to <moved@example.org>"
+				}
+				return ""
+			}}
+			host, port := server.start(t)
+			service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityNone))
+			err := service.Send(context.Background(), &Message{
+				Recipients: Recipients{To: []string{"moved@example.com", "rcpt@example.com"}},
+				Subject:    "Forwarded",
+				TextBody:   "Hello",
+			})
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			messages := server.Messages()
+			if len(messages) != 1 || len(messages[0].Rcpt) != 2 {
+				t.Fatalf("server accepted %+v, want one message for both recipients", messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/smtp"
+	"sync"
+	"time"
+)
+
+// DefaultPoolIdleTimeout is how long a pooled SMTP session may sit unused
+// before it is closed instead of reused. It is kept below the idle timeout
+// of common servers so that sessions are rarely found dropped.
+const DefaultPoolIdleTimeout = 30 * time.Second
+
+// smtpSession is an authenticated SMTP session kept open between messages.
+type smtpSession struct {
+	client   *smtp.Client
+	conn     *deadlineConn
+	lastUsed time.Time
+}
+
+// smtpPool keeps authenticated SMTP sessions open so that batch sends skip
+// the connect, TLS and AUTH round trips. Each session is used by one send at
+// a time; concurrent sends get sessions of their own, up to the pool size.
+type smtpPool struct {
+	idleTimeout time.Duration
+	slots       chan struct{} // Holds one token per session in use, bounding open sessions.
+
+	mu     sync.Mutex
+	idle   []*smtpSession // Most recently used last.
+	closed bool
+}
+
+// newSMTPPool creates an empty pool.
+// Parameters:
+// - size: The maximum number of sessions open at once; values below 1 mean 1.
+// - idleTimeout: How long a session may sit unused before it is closed; zero uses DefaultPoolIdleTimeout.
+func newSMTPPool(size int, idleTimeout time.Duration) *smtpPool {
+	if size < 1 {
+		size = 1
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultPoolIdleTimeout
+	}
+	return &smtpPool{idleTimeout: idleTimeout, slots: make(chan struct{}, size)}
+}
+
+// deliver sends a message on a pooled session. When a reused session turns
+// out to have been closed by the server, by a 421 reply or a dropped
+// connection, the message is sent again on a fresh session right away.
+// Parameters:
+// - ctx: Cancels the send when done.
+// - t: The transport used to open new sessions.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - info: What is known about the message before it is written.
+// - write: Writes the complete message.
+func (p *smtpPool) deliver(ctx context.Context, t *smtpTransport, from string, to []string, info messageInfo, write func(io.Writer) error) error {
+	for {
+		session, reused, err := p.get(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		err = t.deliver(session.client, from, to, info, write)
+		if err == nil {
+			p.put(session)
+			return nil
+		}
+		err = wrapSendError(ctx, session.conn, err)
+		if sessionUsable(err) {
+			p.put(session)
+		} else {
+			p.discard(session)
+		}
+
+		if !reused || ctx.Err() != nil || !sessionLost(err) {
+			return err
+		}
+	}
+}
+
+// get takes an idle session or opens a new one, waiting while the pool is
+// full. Reused sessions are reset with RSET, which also checks that the
+// server has not dropped them. It fails with ErrServiceClosed once the pool
+// is closed.
+// Parameters:
+// - ctx: Cancels the wait and becomes the context of the session.
+// - t: The transport used to open new sessions.
+// Returns the session and whether it was reused.
+func (p *smtpPool) get(ctx context.Context, t *smtpTransport) (*smtpSession, bool, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, fmt.Errorf("email sending aborted: %w", ctx.Err())
+	}
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		<-p.slots
+		return nil, false, ErrServiceClosed
+	}
+
+	for {
+		session := p.takeIdle()
+		if session == nil {
+			break
+		}
+		session.conn.rebind(ctx)
+		if err := session.client.Reset(); err == nil {
+			return session, true, nil
+		}
+		session.client.Close()
+	}
+
+	client, conn, err := t.connect(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, false, wrapSendError(ctx, conn, err)
+	}
+	return &smtpSession{client: client, conn: conn}, false, nil
+}
+
+// takeIdle removes and returns the most recently used idle session, closing
+// those that have been idle for too long. It returns nil if none is left.
+func (p *smtpPool) takeIdle() *smtpSession {
+	p.mu.Lock()
+	cutoff := time.Now().Add(-p.idleTimeout)
+	var expired []*smtpSession
+	for len(p.idle) > 0 && p.idle[0].lastUsed.Before(cutoff) {
+		expired = append(expired, p.idle[0])
+		p.idle = p.idle[1:]
+	}
+	var session *smtpSession
+	if n := len(p.idle); n > 0 {
+		session = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	}
+	p.mu.Unlock()
+
+	// The server has probably timed these out already, so don't wait for a QUIT reply.
+	for _, s := range expired {
+		s.client.Close()
+	}
+	return session
+}
+
+// put returns a session to the pool once its send has finished, or ends it
+// with QUIT if the pool has been closed meanwhile.
+// Parameters:
+// - session: The session, which must be ready for the next message.
+func (p *smtpPool) put(session *smtpSession) {
+	// Detach the session from the finished send's context.
+	session.conn.rebind(context.Background())
+	session.lastUsed = time.Now()
+
+	p.mu.Lock()
+	closed := p.closed
+	if !closed {
+		p.idle = append(p.idle, session)
+	}
+	p.mu.Unlock()
+	if closed {
+		quit(session)
+	}
+	<-p.slots
+}
+
+// discard closes a session that cannot be reused.
+// Parameters:
+// - session: The session to close.
+func (p *smtpPool) discard(session *smtpSession) {
+	session.client.Close()
+	<-p.slots
+}
+
+// close ends every idle session with QUIT and makes later sends fail. Sessions
+// in use are ended when their sends finish.
+func (p *smtpPool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, session := range idle {
+		quit(session)
+	}
+}
+
+// quit ends a session with QUIT, closing the connection if the server does
+// not answer.
+// Parameters:
+// - session: The session to end.
+func quit(session *smtpSession) {
+	if err := session.client.Quit(); err != nil {
+		session.client.Close()
+	}
+}
+
+// sessionUsable reports whether a session can take another message after a
+// failed send: the server answered with a reply other than 421, so the
+// session is still in a known state. Anything else, such as a dropped
+// connection, a timeout or a message that failed to build halfway through
+// DATA, leaves the session unusable.
+// Parameters:
+// - err: The error returned by the send.
+func sessionUsable(err error) bool {
+	var smtpErr *SMTPError
+	return errors.As(err, &smtpErr) && smtpErr.Code != 0 && smtpErr.Code != 421 && !errors.Is(err, ErrTimeout)
+}
+
+// sessionLost reports whether a send failed because the server closed the
+// session, with a 421 reply or by dropping the connection, before the message
+// could have been accepted.
+// Parameters:
+// - err: The error returned by the send.
+func sessionLost(err error) bool {
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) || errors.Is(err, ErrTimeout) {
+		return false
+	}
+	return smtpErr.Code == 421 || smtpErr.Kind == ErrConnection
+}
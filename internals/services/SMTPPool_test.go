@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newPooledService creates a service that sends to server through a
+// connection pool.
+func newPooledService(t *testing.T, server *fakeSMTP, size int, idleTimeout time.Duration) DhanuEmailServiceInterface {
+	t.Helper()
+	host, port := server.start(t)
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone),
+		WithTimeouts(time.Second, time.Second),
+		WithConnectionPool(size, idleTimeout),
+	)
+	t.Cleanup(func() { service.Close() })
+	return service
+}
+
+// sendPooled sends a short message, failing the test on error.
+func sendPooled(t *testing.T, service DhanuEmailServiceInterface, subject string) {
+	t.Helper()
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    subject,
+		TextBody:   "Hello",
+	})
+	if err != nil {
+		t.Fatalf("Send(%q) error = %v", subject, err)
+	}
+}
+
+// commandVerbs returns the verb of each command the server received.
+func commandVerbs(server *fakeSMTP) []string {
+	var verbs []string
+	for _, command := range server.Commands() {
+		verbs = append(verbs, strings.ToUpper(strings.SplitN(command, " ", 2)[0]))
+	}
+	return verbs
+}
+
+// countVerb returns how many commands with the given verb the server received.
+// Every session starts with one EHLO, so counting those counts connections.
+func countVerb(server *fakeSMTP, verb string) int {
+	n := 0
+	for _, v := range commandVerbs(server) {
+		if v == verb {
+			n++
+		}
+	}
+	return n
+}
+
+func TestConnectionPoolConcurrentSends(t *testing.T) {
+	const size, messages = 3, 20
+	server := &fakeSMTP{}
+	service := newPooledService(t, server, size, time.Minute)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, messages)
+	for i := 0; i < messages; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- service.Send(context.Background(), &Message{
+				Recipients: Recipients{To: []string{fmt.Sprintf("rcpt%d@example.com", i)}},
+				Subject:    fmt.Sprintf("Message %d", i),
+				TextBody:   "Hello",
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Send() error = %v", err)
+		}
+	}
+
+	if got := len(server.Messages()); got != messages {
+		t.Errorf("server accepted %d messages, want %d", got, messages)
+	}
+	if got := countVerb(server, "EHLO"); got > size {
+		t.Errorf("pool opened %d sessions, want at most %d", got, size)
+	}
+}
+
+func TestConnectionPoolResetsReusedSessions(t *testing.T) {
+	server := &fakeSMTP{}
+	service := newPooledService(t, server, 1, time.Minute)
+	for i := 0; i < 3; i++ {
+		sendPooled(t, service, fmt.Sprintf("Message %d", i))
+	}
+
+	want := "EHLO MAIL RCPT DATA RSET MAIL RCPT DATA RSET MAIL RCPT DATA"
+	if got := strings.Join(commandVerbs(server), " "); got != want {
+		t.Errorf("commands = %s, want %s", got, want)
+	}
+	if got := len(server.Messages()); got != 3 {
+		t.Errorf("server accepted %d messages, want 3", got)
+	}
+}
+
+func TestConnectionPoolReconnectsAfter421(t *testing.T) {
+	tests := []struct {
+		verb string
+		nth  int32 // Which command with verb is answered with 421: the one for the second message.
+	}{
+		{verb: "RSET", nth: 1},
+		{verb: "MAIL", nth: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.verb, func(t *testing.T) {
+			var seen atomic.Int32
+			server := &fakeSMTP{replies: func(command string) string {
+				if strings.HasPrefix(command, tt.verb) && seen.Add(1) == tt.nth {
+					return "421 4.4.2 idle too long, closing connection"
+				}
+				return ""
+			}}
+			service := newPooledService(t, server, 1, time.Minute)
+			sendPooled(t, service, "First")
+			sendPooled(t, service, "Second")
+
+			if got := len(server.Messages()); got != 2 {
+				t.Errorf("server accepted %d messages, want 2", got)
+			}
+			if got := countVerb(server, "EHLO"); got != 2 {
+				t.Errorf("pool opened %d sessions, want 2", got)
+			}
+		})
+	}
+}
+
+func TestConnectionPoolReconnectsAfterIdleTimeout(t *testing.T) {
+	server := &fakeSMTP{}
+	service := newPooledService(t, server, 1, 50*time.Millisecond)
+	sendPooled(t, service, "First")
+	time.Sleep(100 * time.Millisecond)
+	sendPooled(t, service, "Second")
+
+	if got := countVerb(server, "EHLO"); got != 2 {
+		t.Errorf("pool opened %d sessions, want 2", got)
+	}
+	if got := countVerb(server, "RSET"); got != 0 {
+		t.Errorf("expired session was reset %d times instead of replaced", got)
+	}
+	if got := len(server.Messages()); got != 2 {
+		t.Errorf("server accepted %d messages, want 2", got)
+	}
+}
+
+func TestConnectionPoolClose(t *testing.T) {
+	server := &fakeSMTP{}
+	service := newPooledService(t, server, 2, time.Minute)
+	sendPooled(t, service, "Before close")
+
+	if err := service.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := strings.Join(commandVerbs(server), " "); got != "EHLO MAIL RCPT DATA QUIT" {
+		t.Errorf("commands = %s, want the idle session ended with QUIT", got)
+	}
+
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "After close",
+		TextBody:   "Hello",
+	})
+	if !errors.Is(err, ErrServiceClosed) {
+		t.Errorf("Send() after Close() error = %v, want %v", err, ErrServiceClosed)
+	}
+	if got := countVerb(server, "EHLO"); got != 1 {
+		t.Errorf("pool opened %d sessions, want 1 before Close() and none after", got)
+	}
+}
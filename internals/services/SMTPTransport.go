@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/smtp"
+	"sync"
+	"time"
+)
+
+// smtpTransport delivers messages to an SMTP server. It is the service's
+// default transport and is configured through the service options.
+type smtpTransport struct {
+	host        string
+	port        string
+	username    string
+	credentials string
+	security    SecurityMode
+	tlsConfig   *tls.Config
+	auth        string
+	dialTimeout time.Duration
+	ioTimeout   time.Duration
+	hello       string   // EHLO name; empty uses the machine's FQDN.
+	localAddr   string   // Local IP address or interface to dial from.
+	ipFamily    IPFamily // IP version to connect over.
+	dsn         DSNOptions
+	pool        *smtpPool // Reuses sessions between messages; nil opens one per message.
+
+	fqdnMu       sync.Mutex
+	fqdn         string // The machine's FQDN, looked up on the first connection.
+	fqdnResolved bool   // Whether a lookup of fqdn has completed.
+}
+
+// Deliver sends a message in one SMTP session.
+// Parameters:
+// - ctx: Cancels the SMTP session when done.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - message: The complete message.
+func (t *smtpTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	return t.deliverStream(ctx, from, to, rawMessageInfo(message), func(w io.Writer) error {
+		_, err := io.WriteString(w, message)
+		return err
+	})
+}
+
+// DeliverStream sends a message, writing it straight into the DATA command as
+// it is built. Without a pool every message gets its own SMTP session.
+// Parameters:
+// - ctx: Cancels the SMTP session when done.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - write: Writes the complete message.
+func (t *smtpTransport) DeliverStream(ctx context.Context, from string, to []string, write func(io.Writer) error) error {
+	return t.deliverStream(ctx, from, to, unknownMessageInfo, write)
+}
+
+// deliverStream is DeliverStream for a message whose size is known or estimated.
+// Parameters:
+// - ctx: Cancels the SMTP session when done.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - info: What is known about the message before it is written.
+// - write: Writes the complete message.
+func (t *smtpTransport) deliverStream(ctx context.Context, from string, to []string, info messageInfo, write func(io.Writer) error) error {
+	if t.pool != nil {
+		return t.pool.deliver(ctx, t, from, to, info, write)
+	}
+
+	client, conn, err := t.connect(ctx)
+	if err != nil {
+		return wrapSendError(ctx, conn, err)
+	}
+	defer client.Close()
+
+	// Report cancellations and timeouts as such rather than as protocol failures.
+	if err := t.deliver(client, from, to, info, write); err != nil {
+		return wrapSendError(ctx, conn, err)
+	}
+
+	// The message has been accepted at this point, so a failed QUIT is not
+	// reported as an error; doing so would make a retry deliver it twice.
+	client.Quit()
+	return nil
+}
+
+// connect opens an SMTP session, secures it according to the configured
+// security mode and authenticates with the mechanism negotiated from the
+// EHLO AUTH list.
+// Parameters:
+// - ctx: Cancelling the context aborts the session.
+// Returns the client and the underlying connection, which is non-nil whenever the TCP connection succeeded.
+func (t *smtpTransport) connect(ctx context.Context) (*smtp.Client, *deadlineConn, error) {
+	client, conn, err := t.dial(ctx)
+	if err != nil {
+		return nil, conn, err
+	}
+
+	_, advertised := client.Extension("AUTH")
+	auth, err := t.selectAuth(advertised)
+	if err != nil {
+		client.Close()
+		return nil, conn, err
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, conn, newSMTPError(ErrAuth, err)
+		}
+	}
+	return client, conn, nil
+}
+
+// deliver runs the SMTP transaction for one message on an established session.
+// Parameters:
+// - client: The connected, secured and authenticated SMTP client.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - info: What is known about the message before it is written.
+// - write: Writes the complete message.
+func (t *smtpTransport) deliver(client *smtp.Client, from string, to []string, info messageInfo, write func(io.Writer) error) error {
+	// Check the size against the server's limit before uploading anything.
+	if limit := sizeLimit(client); limit > 0 && info.size > limit {
+		return newSMTPError(ErrMessageTooLarge, fmt.Errorf("message is at least %d bytes, the server accepts at most %d", info.size, limit))
+	}
+
+	if err := t.sendEnvelope(client, from, to, info); err != nil {
+		return err
+	}
+
+	// Upload the message.
+	writer, err := client.Data()
+	if err != nil {
+		return newSMTPError(ErrMessageRejected, err)
+	}
+	out := &errorWriter{w: writer}
+	if err := write(out); err != nil {
+		// Return without ending DATA: closing the connection makes the server
+		// discard the partial message.
+		if out.err != nil {
+			return newSMTPError(ErrConnection, out.err)
+		}
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return newSMTPError(ErrMessageRejected, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"strings"
+)
+
+// Every value that ends up in a header line passes through this file. Values
+// with their own syntax (addresses, Message-IDs, custom headers) are rejected
+// when they contain control characters; free text (the subject) and file
+// names are sanitised instead, because they commonly come from scripts and
+// file systems. checkHeaderBlock verifies the result as a last line of defence.
+
+// isControl reports whether r is a C0 control character or DEL. Tab is
+// allowed, as it is legal whitespace in header values.
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || r == 0x7f
+}
+
+// containsControl reports whether s contains a control character.
+func containsControl(s string) bool {
+	return strings.IndexFunc(s, isControl) >= 0
+}
+
+// sanitizeHeaderText makes free text safe for an unstructured header such as
+// the subject: every run of line breaks becomes a single space and other
+// control characters are removed.
+// Parameters:
+// - text: The text to sanitise.
+func sanitizeHeaderText(text string) string {
+	if !containsControl(text) {
+		return text
+	}
+	var b strings.Builder
+	lineBreak := false
+	for _, r := range text {
+		switch {
+		case r == '\r' || r == '\n':
+			if !lineBreak {
+				b.WriteByte(' ')
+			}
+			lineBreak = true
+			continue
+		case isControl(r):
+		default:
+			b.WriteRune(r)
+		}
+		lineBreak = false
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// sanitizeFileName replaces control characters in a file name with '_' so it
+// can be used in Content-Type and Content-Disposition parameters.
+// Parameters:
+// - name: The file name.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// checkAddress rejects addresses containing control characters before they are parsed.
+// Parameters:
+// - address: The address, optionally in "Name <user@example.com>" form.
+func checkAddress(address string) error {
+	if containsControl(address) {
+		return fmt.Errorf("%w: address %q contains control characters", ErrInvalidHeader, address)
+	}
+	return nil
+}
+
+// validateMsgID checks a Message-ID or Content-ID given without angle brackets.
+// Parameters:
+// - field: The header the ID is written to, used in the error.
+// - id: The ID, e.g. "1234.abcd@example.com".
+func validateMsgID(field, id string) error {
+	if containsControl(id) || strings.ContainsAny(id, " \t<>") || !strings.Contains(id, "@") {
+		return fmt.Errorf("%w: invalid %s %q", ErrInvalidHeader, field, id)
+	}
+	return nil
+}
+
+// validateCustomHeader checks that a custom header has a legal field name, is
+// not a reserved header and cannot inject additional header lines.
+// Parameters:
+// - name: The header field name.
+// - value: The header value.
+func validateCustomHeader(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("%w: custom header has an empty name", ErrInvalidHeader)
+	}
+	if !isFieldName(name) {
+		return fmt.Errorf("%w: custom header name %q contains an invalid character", ErrInvalidHeader, name)
+	}
+	if reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+		return fmt.Errorf("%w: header %s cannot be set as a custom header", ErrInvalidHeader, name)
+	}
+	if containsControl(value) {
+		return fmt.Errorf("%w: custom header %s contains control characters", ErrInvalidHeader, name)
+	}
+	return nil
+}
+
+// isFieldName reports whether name is a legal header field name: printable
+// ASCII without the colon (RFC 5322 section 2.2).
+func isFieldName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < 33 || name[i] > 126 || name[i] == ':' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// checkHeaderBlock verifies a rendered header block: every line ends in CRLF
+// and is either a "Name: value" field or a folded continuation line, and no
+// control characters appear anywhere. It guards against any value that
+// slipped past the checks above.
+// Parameters:
+// - block: The header lines, each terminated by CRLF.
+func checkHeaderBlock(block []byte) error {
+	lines := bytes.SplitAfter(block, []byte("\r\n"))
+	for i, line := range lines {
+		if len(line) == 0 && i == len(lines)-1 {
+			break
+		}
+		if !bytes.HasSuffix(line, []byte("\r\n")) {
+			return fmt.Errorf("%w: unterminated header line %q", ErrInvalidHeader, line)
+		}
+		content := string(line[:len(line)-2])
+		if containsControl(content) {
+			return fmt.Errorf("%w: control characters in header line %q", ErrInvalidHeader, content)
+		}
+		if content == "" {
+			return fmt.Errorf("%w: empty line inside the header block", ErrInvalidHeader)
+		}
+		if content[0] == ' ' || content[0] == '\t' {
+			if i == 0 {
+				return fmt.Errorf("%w: header block starts with a continuation line", ErrInvalidHeader)
+			}
+			continue
+		}
+		name, _, ok := strings.Cut(content, ":")
+		if !ok || !isFieldName(name) {
+			return fmt.Errorf("%w: malformed header line %q", ErrInvalidHeader, content)
+		}
+	}
+	return nil
+}
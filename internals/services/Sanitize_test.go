@@ -0,0 +1,91 @@
+package services
+
+import (
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// FuzzBuildMessage checks that no header-bound value can add header fields:
+// whatever the subject, display names, attachment name and custom header,
+// the message either fails to build or parses with exactly the expected fields.
+func FuzzBuildMessage(f *testing.F) {
+	f.Add("Weekly report", "Reports", "Team", "report.pdf", "X-Priority", "1")
+	f.Add("Hi\r\nBcc: attacker@example.com", "Eve\r\nBcc: a@x", "Bob\nCc: c@x", "a\r\nBcc: b@x.pdf", "X-Tag", "v\r\nBcc: attacker@example.com")
+	f.Add("Grüße =?utf-8?q?x?=", "नमस्ते", "\"quoted\" <name>", "Übersicht.txt", "X-Long", strings.Repeat("word ", 40))
+	f.Add("\r\n\r\nbody injection", "", "", "file\x00name", "Bcc", "attacker@example.com")
+	f.Add("tab\tsubject", "a,b", "c;d", "quote\".txt", "Date", "Mon, 02 Jan 2006 15:04:05 -0700")
+
+	f.Fuzz(func(t *testing.T, subject, fromName, toName, fileName, headerName, headerValue string) {
+		attachment := filepath.Join(t.TempDir(), "attachment")
+		if fileName != "" && !strings.ContainsAny(fileName, "/\x00") && fileName != "." && fileName != ".." {
+			attachment = filepath.Join(filepath.Dir(attachment), fileName)
+		}
+		if err := os.WriteFile(attachment, []byte("content"), 0o600); err != nil {
+			t.Skip("file name not supported by the file system")
+		}
+
+		msg := &Message{
+			Recipients:  Recipients{To: []string{(&mail.Address{Name: toName, Address: "rcpt@example.com"}).String()}},
+			Subject:     subject,
+			TextBody:    "Hello",
+			Attachments: []string{attachment},
+		}
+		if headerName != "" {
+			msg.Headers = []Header{{Name: headerName, Value: headerValue}}
+		}
+		service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret", WithFromName(fromName)).(*DhanuEmailService)
+		data, err := service.buildMessage(msg)
+		if err != nil {
+			return
+		}
+
+		parsed, err := mail.ReadMessage(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("net/mail cannot parse the message: %v\n%s", err, data)
+		}
+		want := map[string]bool{
+			"Mime-Version": true, "Date": true, "Message-Id": true, "From": true,
+			"To": true, "Subject": true, "X-Mailer": true, "Content-Type": true,
+		}
+		if headerName != "" {
+			want[textproto.CanonicalMIMEHeaderKey(headerName)] = true
+		}
+		for name, values := range parsed.Header {
+			if !want[name] {
+				t.Fatalf("message gained header %s: %q\n%s", name, values, data)
+			}
+			if len(values) != 1 {
+				t.Fatalf("message has %d %s headers\n%s", len(values), name, data)
+			}
+		}
+
+		_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("invalid Content-Type: %v", err)
+		}
+		reader := multipart.NewReader(parsed.Body, params["boundary"])
+		partHeaders := map[string]bool{"Content-Type": true, "Content-Transfer-Encoding": true, "Content-Disposition": true}
+		parts := 0
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				break
+			}
+			parts++
+			for name := range part.Header {
+				if !partHeaders[name] {
+					t.Fatalf("part %d gained header %s\n%s", parts, name, data)
+				}
+			}
+		}
+		if parts != 2 {
+			t.Fatalf("message has %d parts, want 2\n%s", parts, data)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SecurityMode describes how the connection to the SMTP server is secured.
+type SecurityMode string
+
+const (
+	// SecurityAuto picks implicit TLS for port 465 and opportunistic STARTTLS otherwise.
+	SecurityAuto SecurityMode = "auto"
+	// SecurityTLS wraps the connection in TLS before any SMTP traffic (SMTPS, usually port 465).
+	SecurityTLS SecurityMode = "tls"
+	// SecurityStartTLS upgrades a plain connection with STARTTLS and fails if the server does not offer it.
+	SecurityStartTLS SecurityMode = "starttls"
+	// SecurityOpportunistic upgrades with STARTTLS when the server offers it and stays plain otherwise.
+	SecurityOpportunistic SecurityMode = "opportunistic"
+	// SecurityNone never encrypts the connection.
+	SecurityNone SecurityMode = "none"
+)
+
+// ParseSecurityMode converts a configuration value into a SecurityMode.
+// An empty value is treated as SecurityAuto.
+// Parameters:
+// - value: The configured security mode.
+func ParseSecurityMode(value string) (SecurityMode, error) {
+	switch mode := SecurityMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return SecurityAuto, nil
+	case SecurityAuto, SecurityTLS, SecurityStartTLS, SecurityOpportunistic, SecurityNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown SMTP security mode %q (expected auto, tls, starttls, opportunistic or none)", value)
+	}
+}
+
+// resolveSecurity returns the effective security mode for the configured port.
+func (t *smtpTransport) resolveSecurity() SecurityMode {
+	if t.security == "" || t.security == SecurityAuto {
+		if t.port == "465" {
+			return SecurityTLS
+		}
+		return SecurityOpportunistic
+	}
+	return t.security
+}
+
+// clientTLSConfig returns the TLS configuration for the SMTP connection,
+// filling in the server name from the SMTP host when it is not set.
+func (t *smtpTransport) clientTLSConfig() *tls.Config {
+	config := &tls.Config{}
+	if t.tlsConfig != nil {
+		config = t.tlsConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = t.host
+	}
+	return config
+}
+
+// dial connects to the SMTP server and secures the connection according to the security mode.
+// The returned client has completed the TLS handshake (implicit or STARTTLS) where applicable.
+// Parameters:
+// - ctx: Cancelling the context aborts the dial and any later I/O on the connection.
+// Returns the client and the underlying connection, which is non-nil whenever the TCP connection succeeded.
+func (t *smtpTransport) dial(ctx context.Context) (*smtp.Client, *deadlineConn, error) {
+	addr := net.JoinHostPort(t.host, t.port)
+	mode := t.resolveSecurity()
+	tlsConfig := t.clientTLSConfig()
+
+	dialer, err := t.newDialer()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rawConn net.Conn
+	if mode == SecurityTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+		rawConn, err = tlsDialer.DialContext(ctx, t.network(), addr)
+	} else {
+		rawConn, err = dialer.DialContext(ctx, t.network(), addr)
+	}
+	if err != nil {
+		var netErr net.Error
+		if ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, nil, fmt.Errorf("%w: %w", ErrTimeout, newSMTPError(ErrConnection, err))
+		}
+		// A failed implicit TLS handshake is reported as a TLS error, not a connection error.
+		if isTLSFailure(err) {
+			return nil, nil, newSMTPError(ErrTLS, err)
+		}
+		return nil, nil, newSMTPError(ErrConnection, err)
+	}
+
+	// Bound every command by the I/O timeout and let the context interrupt it.
+	conn := newDeadlineConn(ctx, rawConn, t.ioTimeout)
+
+	client, err := smtp.NewClient(conn, t.host)
+	if err != nil {
+		conn.Close()
+		kind := ErrConnection
+		// With TLS 1.3 the server checks the client certificate after the
+		// client has finished the handshake, so its alert arrives in place
+		// of the greeting.
+		if mode == SecurityTLS && isTLSFailure(err) {
+			kind = ErrTLS
+		}
+		return nil, conn, newSMTPError(kind, fmt.Errorf("failed to start SMTP session with %s: %w", addr, err))
+	}
+
+	// Greet the server with our own name rather than net/smtp's "localhost".
+	if err := client.Hello(t.helloName(ctx, rawConn)); err != nil {
+		client.Close()
+		return nil, conn, newSMTPError(ErrConnection, fmt.Errorf("SMTP server %s rejected EHLO: %w", addr, err))
+	}
+
+	if mode == SecurityStartTLS || mode == SecurityOpportunistic {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, conn, newSMTPError(ErrTLS, fmt.Errorf("failed to negotiate STARTTLS with %s: %w", addr, err))
+			}
+		} else if mode == SecurityStartTLS {
+			client.Close()
+			return nil, conn, newSMTPError(ErrTLS, fmt.Errorf("SMTP server %s does not support STARTTLS", addr))
+		}
+	}
+
+	return client, conn, nil
+}
+
+// isTLSFailure reports whether err comes from TLS rather than the connection
+// beneath it: a malformed record, an unverified certificate or an alert sent
+// by the server, e.g. for a missing client certificate.
+// Parameters:
+// - err: The error returned by the TLS connection.
+func isTLSFailure(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var opErr *net.OpError
+	return errors.As(err, &recordErr) || errors.As(err, &certErr) || (errors.As(err, &opErr) && opErr.Op == "remote error")
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSecurityModes(t *testing.T) {
+	serverTLS, roots := newTestCertificate(t)
+
+	tests := []struct {
+		name          string
+		mode          SecurityMode
+		implicit      bool
+		startTLS      bool
+		wantEncrypted bool
+		wantErr       error
+	}{
+		{name: "implicit TLS", mode: SecurityTLS, implicit: true, wantEncrypted: true},
+		{name: "required STARTTLS", mode: SecurityStartTLS, startTLS: true, wantEncrypted: true},
+		{name: "required STARTTLS not advertised", mode: SecurityStartTLS, wantErr: ErrTLS},
+		{name: "opportunistic with STARTTLS", mode: SecurityOpportunistic, startTLS: true, wantEncrypted: true},
+		{name: "opportunistic without STARTTLS", mode: SecurityOpportunistic},
+		{name: "none ignores STARTTLS", mode: SecurityNone, startTLS: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTP{tlsConfig: serverTLS, implicit: tt.implicit, startTLS: tt.startTLS}
+			host, port := server.start(t)
+			service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+				WithSecurity(tt.mode),
+				WithTLSConfig(&tls.Config{RootCAs: roots}),
+			)
+
+			err := service.Send(context.Background(), &Message{
+				Recipients: Recipients{To: []string{"rcpt@example.com"}},
+				Subject:    "Security test",
+				TextBody:   "Hello",
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+				}
+				if messages := server.Messages(); len(messages) != 0 {
+					t.Fatalf("server received %d messages, want none", len(messages))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("server received %d messages, want 1", len(messages))
+			}
+			if messages[0].TLS != tt.wantEncrypted {
+				t.Errorf("message sent encrypted = %v, want %v", messages[0].TLS, tt.wantEncrypted)
+			}
+			if !strings.Contains(messages[0].Data, "Subject: Security test\r\n") {
+				t.Errorf("message is missing its subject:\n%s", messages[0].Data)
+			}
+		})
+	}
+}
+
+func TestImplicitTLSRejectsUntrustedCertificate(t *testing.T) {
+	serverTLS, _ := newTestCertificate(t)
+	server := &fakeSMTP{tlsConfig: serverTLS, implicit: true}
+	host, port := server.start(t)
+
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret", WithSecurity(SecurityTLS))
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "Security test",
+		TextBody:   "Hello",
+	})
+	if !errors.Is(err, ErrTLS) {
+		t.Fatalf("Send() error = %v, want %v", err, ErrTLS)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+)
+
+// DefaultSendGridEndpoint is the base URL of SendGrid's v3 API.
+const DefaultSendGridEndpoint = "https://api.sendgrid.com"
+
+// SendGridTransport sends messages through SendGrid's v3 Mail Send API.
+// SendGrid only accepts structured JSON, so the message is taken apart into
+// its recipients, bodies, headers and attachments; SendGrid then rebuilds and
+// DKIM signs it.
+type SendGridTransport struct {
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+// NewSendGridTransport creates a SendGrid transport.
+// Parameters:
+// - apiKey: An API key with the Mail Send permission.
+// - endpoint: The API base URL, e.g. "https://api.eu.sendgrid.com"; empty uses DefaultSendGridEndpoint.
+func NewSendGridTransport(apiKey, endpoint string) *SendGridTransport {
+	return &SendGridTransport{
+		apiKey:   apiKey,
+		endpoint: apiEndpoint(endpoint, DefaultSendGridEndpoint),
+		client:   newAPIClient(),
+	}
+}
+
+// sendGridAddress is an address in a SendGrid request.
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// sendGridRequest is the body of a Mail Send request.
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyToList      []sendGridAddress         `json:"reply_to_list,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+// sendGridPersonalization lists the recipients of a SendGrid request.
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to,omitempty"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+// sendGridContent is a body of a SendGrid request.
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sendGridAttachment is an attachment or inline image in a SendGrid request.
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// Deliver sends the message with one Mail Send request.
+// Parameters:
+// - ctx: Cancels the request when done.
+// - from: The envelope sender (unused; SendGrid uses the From header).
+// - to: The envelope recipients, including any Bcc addresses.
+// - message: The complete message with CRLF line endings.
+func (t *SendGridTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	parsed, err := parseAPIMessage(TransportSendGrid, message, to)
+	if err != nil {
+		return err
+	}
+
+	payload := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{
+			To:  sendGridAddresses(parsed.To),
+			Cc:  sendGridAddresses(parsed.Cc),
+			Bcc: sendGridAddresses(parsed.Bcc),
+		}},
+		From:        sendGridAddress{Email: parsed.From.Address, Name: parsed.From.Name},
+		ReplyToList: sendGridAddresses(parsed.ReplyTo),
+		Subject:     parsed.Subject,
+	}
+	// SendGrid requires the plain-text content to come before the HTML.
+	for _, content := range []struct{ kind, value string }{{"text/plain", parsed.Text}, {"text/html", parsed.HTML}} {
+		if content.value != "" {
+			payload.Content = append(payload.Content, sendGridContent{Type: content.kind, Value: content.value})
+		}
+	}
+	for _, attachment := range parsed.Attachments {
+		disposition := "attachment"
+		if attachment.ContentID != "" {
+			disposition = "inline"
+		}
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(attachment.Content),
+			Type:        attachment.ContentType,
+			Filename:    attachment.Name,
+			Disposition: disposition,
+			ContentID:   attachment.ContentID,
+		})
+	}
+	if len(parsed.Headers) > 0 {
+		payload.Headers = make(map[string]string, len(parsed.Headers))
+		for _, header := range parsed.Headers {
+			payload.Headers[header.Name] = header.Value
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode SendGrid request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	return doAPIRequest(t.client, TransportSendGrid, req)
+}
+
+// sendGridAddresses converts parsed addresses for a SendGrid request.
+func sendGridAddresses(addresses []*mail.Address) []sendGridAddress {
+	var converted []sendGridAddress
+	for _, address := range addresses {
+		converted = append(converted, sendGridAddress{Email: address.Address, Name: address.Name})
+	}
+	return converted
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSendGridTransport(t *testing.T) {
+	standIn := newAPIStandIn(t, http.StatusAccepted, "")
+	envelope, data := composeAPITestMessage(t)
+
+	transport := NewSendGridTransport("SG.test-key", standIn.URL+"/")
+	if err := transport.Deliver(context.Background(), "reports@example.com", envelope, data); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	req := standIn.request(t)
+	if req.Method != http.MethodPost || req.Path != "/v3/mail/send" {
+		t.Errorf("request = %s %s, want POST /v3/mail/send", req.Method, req.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer SG.test-key" {
+		t.Errorf("Authorization = %q, want the bearer API key", got)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	var payload sendGridRequest
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatalf("request body is not JSON: %v", err)
+	}
+	want := []sendGridPersonalization{{
+		To:  []sendGridAddress{{Email: "alice@example.com", Name: "Alice"}},
+		Cc:  []sendGridAddress{{Email: "carol@example.com"}},
+		Bcc: []sendGridAddress{{Email: "hidden@example.com"}},
+	}}
+	if !reflect.DeepEqual(payload.Personalizations, want) {
+		t.Errorf("personalizations = %+v, want %+v", payload.Personalizations, want)
+	}
+	if payload.From != (sendGridAddress{Email: "reports@example.com", Name: "Reports"}) {
+		t.Errorf("from = %+v", payload.From)
+	}
+	if !reflect.DeepEqual(payload.ReplyToList, []sendGridAddress{{Email: "replies@example.com"}}) {
+		t.Errorf("reply_to_list = %+v", payload.ReplyToList)
+	}
+	if payload.Subject != "Quarterly report" {
+		t.Errorf("subject = %q", payload.Subject)
+	}
+	wantContent := []sendGridContent{
+		{Type: "text/plain", Value: "See the report."},
+		{Type: "text/html", Value: `<p>See the report.</p><img src="cid:logo@example.com">`},
+	}
+	if !reflect.DeepEqual(payload.Content, wantContent) {
+		t.Errorf("content = %+v, want %+v", payload.Content, wantContent)
+	}
+	if payload.Headers["X-Campaign"] != "q3" {
+		t.Errorf("headers = %v, want X-Campaign: q3", payload.Headers)
+	}
+	for name := range payload.Headers {
+		if name == "Bcc" || name == "To" || name == "From" {
+			t.Errorf("headers repeat the %s field", name)
+		}
+	}
+
+	if len(payload.Attachments) != 2 {
+		t.Fatalf("request has %d attachments, want 2", len(payload.Attachments))
+	}
+	attachments := map[string]sendGridAttachment{}
+	for _, attachment := range payload.Attachments {
+		attachments[attachment.Filename] = attachment
+	}
+	report, logo := attachments["report.csv"], attachments["logo.png"]
+	if report.Disposition != "attachment" || report.ContentID != "" || decodeBase64(t, report.Content) != "a,b\r\n1,2\r\n" {
+		t.Errorf("report attachment = %+v", report)
+	}
+	if logo.Disposition != "inline" || logo.ContentID != "logo@example.com" || logo.Type != "image/png" ||
+		decodeBase64(t, logo.Content) != string(testInlineImage) {
+		t.Errorf("inline image = %+v, want inline with content_id logo@example.com", logo)
+	}
+}
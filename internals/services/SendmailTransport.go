@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// DefaultSendmailPath is the usual location of the sendmail binary installed
+// by Postfix, Exim, OpenSMTPD and sendmail itself.
+const DefaultSendmailPath = "/usr/sbin/sendmail"
+
+// SendmailTransport hands messages to the local MTA by piping them into a
+// sendmail-compatible binary.
+type SendmailTransport struct {
+	path string
+}
+
+// NewSendmailTransport creates a transport that pipes messages into sendmail.
+// Parameters:
+// - path: The sendmail binary; empty uses DefaultSendmailPath.
+func NewSendmailTransport(path string) *SendmailTransport {
+	if path == "" {
+		path = DefaultSendmailPath
+	}
+	return &SendmailTransport{path: path}
+}
+
+// Deliver runs "sendmail -i -f from -- recipients..." with the message on stdin.
+// The recipients are passed explicitly rather than with -t, which reads them
+// from the headers and would therefore drop Bcc recipients.
+// Parameters:
+// - ctx: Kills sendmail when done before it exits.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - message: The complete message with CRLF line endings.
+func (t *SendmailTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	return t.DeliverStream(ctx, from, to, func(w io.Writer) error {
+		_, err := io.WriteString(w, message)
+		return err
+	})
+}
+
+// DeliverStream runs sendmail like Deliver and pipes the message into it as
+// it is built. When the message fails to build, sendmail is killed before it
+// reaches the end of its input, so the partial message is not queued.
+// Parameters:
+// - ctx: Kills sendmail when done before it exits.
+// - from: The envelope sender.
+// - to: The envelope recipients, including any Bcc addresses.
+// - write: Writes the complete message.
+func (t *SendmailTransport) DeliverStream(ctx context.Context, from string, to []string, write func(io.Writer) error) error {
+	args := []string{"-i", "-f", from, "--"}
+	for _, recipient := range to {
+		args = append(args, envelopeAddress(recipient))
+	}
+
+	cmd := exec.CommandContext(ctx, t.path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("sendmail failed: %v", err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("sendmail failed: %v", err)
+	}
+
+	// Local mailers expect the platform's LF line endings on stdin.
+	buffered := bufio.NewWriter(stdin)
+	converter := &lfWriter{w: buffered}
+	out := &errorWriter{w: converter}
+	if err := write(out); err != nil && out.err == nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	// A write error means sendmail stopped reading; its exit status explains why.
+	if out.err == nil {
+		if err := converter.Flush(); err == nil {
+			buffered.Flush()
+		}
+	}
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("sendmail aborted: %w", ctx.Err())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("sendmail failed: %v: %s", err, msg)
+		}
+		return fmt.Errorf("sendmail failed: %v", err)
+	}
+	if out.err != nil {
+		return fmt.Errorf("sendmail failed: %v", out.err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSendmail writes a sendmail stand-in that records its arguments, one
+// per line, and its standard input in dir, then runs the given shell commands.
+// Returns the path of the script.
+func fakeSendmail(t *testing.T, dir, commands string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sendmail is a shell script")
+	}
+	script := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > '" + filepath.Join(dir, "args") + "'\n" +
+		"cat > '" + filepath.Join(dir, "stdin") + "'\n" +
+		commands + "\n"
+	path := filepath.Join(dir, "sendmail")
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSendmailTransport(t *testing.T) {
+	dir := t.TempDir()
+	service := NewDhanuEmailService("localhost", "25", "sender@example.com", "secret",
+		WithTransport(NewSendmailTransport(fakeSendmail(t, dir, "exit 0"))))
+
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{
+			To:  []string{"Ann <to@example.com>"},
+			Cc:  []string{"cc@example.com"},
+			Bcc: []string{"hidden@example.com"},
+		},
+		Subject:  "Sendmail test",
+		TextBody: "line one\r\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-i\n-f\nsender@example.com\n--\nto@example.com\ncc@example.com\nhidden@example.com\n"
+	if string(args) != want {
+		t.Errorf("sendmail arguments = %q, want %q", args, want)
+	}
+
+	stdin, err := os.ReadFile(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stdin), "\r") {
+		t.Error("message on stdin has CR line endings, want LF")
+	}
+	if !strings.Contains(string(stdin), "\nSubject: Sendmail test\n") || !strings.Contains(string(stdin), "\nTo: \"Ann\" <to@example.com>\n") {
+		t.Errorf("message on stdin is missing its headers:\n%s", stdin)
+	}
+	if strings.Contains(string(stdin), "hidden@example.com") {
+		t.Errorf("Bcc recipient appears in the message:\n%s", stdin)
+	}
+}
+
+func TestSendmailTransportDeliverExact(t *testing.T) {
+	dir := t.TempDir()
+	transport := NewSendmailTransport(fakeSendmail(t, dir, "exit 0"))
+	if err := transport.Deliver(context.Background(), "sender@example.com", []string{"rcpt@example.com"}, testTransportMessage); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	stdin, err := os.ReadFile(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.ReplaceAll(testTransportMessage, "\r\n", "\n"); string(stdin) != want {
+		t.Errorf("stdin = %q, want %q", stdin, want)
+	}
+}
+
+func TestSendmailTransportFailure(t *testing.T) {
+	dir := t.TempDir()
+	transport := NewSendmailTransport(fakeSendmail(t, dir, "echo 'recipient address rejected' >&2; exit 67"))
+	err := transport.Deliver(context.Background(), "sender@example.com", []string{"rcpt@example.com"}, testTransportMessage)
+	if err == nil || !strings.Contains(err.Error(), "recipient address rejected") {
+		t.Fatalf("Deliver() error = %v, want one with sendmail's message", err)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions describe the TLS settings of the SMTP connection, for servers
+// with a certificate from a private CA or that require client certificates.
+type TLSOptions struct {
+	CAFile             string   // PEM bundle of CA certificates trusted instead of the system roots.
+	CertFile           string   // PEM client certificate for mutual TLS, optionally followed by its chain.
+	KeyFile            string   // PEM private key of the client certificate.
+	ServerName         string   // Name the server certificate must match; defaults to the SMTP host.
+	MinVersion         string   // Lowest accepted version: "1.0", "1.1", "1.2" or "1.3"; empty uses Go's default.
+	Fingerprints       []string // SHA-256 fingerprints in hex; the server certificate must match one of them.
+	InsecureSkipVerify bool     // Accept any server certificate. Only for testing, or with Fingerprints.
+}
+
+// tlsVersions maps the accepted MinVersion values to their TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig builds the TLS configuration described by opts, loading the
+// CA bundle and client certificate, for use with WithTLSConfig.
+// Parameters:
+// - opts: The TLS settings; the zero value gives Go's defaults.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.MinVersion != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(opts.MinVersion)), "TLS")]
+		if !ok {
+			return nil, fmt.Errorf("unknown minimum TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", opts.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if opts.CAFile != "" {
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA file %s contains no PEM certificates", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("a client certificate requires both a certificate and a key file")
+		}
+		pair, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	if len(opts.Fingerprints) > 0 {
+		var pins [][]byte
+		for _, fingerprint := range opts.Fingerprints {
+			pin, err := parseFingerprint(fingerprint)
+			if err != nil {
+				return nil, err
+			}
+			pins = append(pins, pin)
+		}
+		// VerifyConnection runs after the usual chain verification, so a
+		// pin narrows which certificates are accepted rather than replacing
+		// the check, unless InsecureSkipVerify is set.
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("server sent no certificate")
+			}
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+			return &tls.CertificateVerificationError{
+				UnverifiedCertificates: state.PeerCertificates,
+				Err:                    fmt.Errorf("server certificate fingerprint %s is not pinned", hex.EncodeToString(sum[:])),
+			}
+		}
+	}
+	return config, nil
+}
+
+// parseFingerprint decodes a SHA-256 fingerprint written in hex, with or
+// without colons, e.g. "AB:CD:...".
+// Parameters:
+// - fingerprint: The fingerprint to decode.
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	cleaned := strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(fingerprint))
+	pin, err := hex.DecodeString(cleaned)
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid certificate fingerprint %q: expected a SHA-256 hash in hex", fingerprint)
+	}
+	return pin, nil
+}
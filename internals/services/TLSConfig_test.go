@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePEM writes a single PEM block to a file in dir.
+// Returns the path of the file.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCertificate creates a self-signed client certificate and writes
+// it and its key as PEM files to dir.
+// Returns the certificate and key files and a pool trusting the certificate.
+func newClientCertificate(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "PRIVATE KEY", keyDER), pool
+}
+
+// sendOverTLS sends a message over implicit TLS with the configuration
+// built from opts.
+// Returns the messages the server accepted and the error of NewTLSConfig or Send.
+func sendOverTLS(t *testing.T, server *fakeSMTP, opts TLSOptions) ([]fakeMessage, error) {
+	t.Helper()
+	server.implicit = true
+	host, port := server.start(t)
+	config, err := NewTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityTLS),
+		WithTLSConfig(config),
+	)
+	err = service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "TLS test",
+		TextBody:   "Hello",
+	})
+	return server.Messages(), err
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	serverTLS, _ := newTestCertificate(t)
+	serverCert := serverTLS.Certificates[0].Certificate[0]
+	sum := sha256.Sum256(serverCert)
+	pin := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var colonPin []string
+	for i := 0; i < len(pin); i += 2 {
+		colonPin = append(colonPin, pin[i:i+2])
+	}
+
+	dir := t.TempDir()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", serverCert)
+	clientCert, clientKey, clientRoots := newClientCertificate(t, dir)
+	mutualTLS := &tls.Config{
+		Certificates: serverTLS.Certificates,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientRoots,
+	}
+	tls12Only := &tls.Config{Certificates: serverTLS.Certificates, MaxVersion: tls.VersionTLS12}
+
+	tests := []struct {
+		name    string
+		server  *tls.Config
+		opts    TLSOptions
+		wantErr error // nil means the message must be delivered.
+	}{
+		{name: "untrusted certificate", server: serverTLS, wantErr: ErrTLS},
+		{name: "CA file", server: serverTLS, opts: TLSOptions{CAFile: caFile}},
+		{name: "CA file for another server name", server: serverTLS, opts: TLSOptions{CAFile: caFile, ServerName: "mail.example.com"}, wantErr: ErrTLS},
+		{name: "matching pin", server: serverTLS, opts: TLSOptions{Fingerprints: []string{strings.Join(colonPin, ":")}, InsecureSkipVerify: true}},
+		{name: "matching pin with CA file", server: serverTLS, opts: TLSOptions{CAFile: caFile, Fingerprints: []string{strings.ToLower(pin)}}},
+		{name: "mismatched pin", server: serverTLS, opts: TLSOptions{CAFile: caFile, Fingerprints: []string{strings.Repeat("ab", sha256.Size)}}, wantErr: ErrTLS},
+		{name: "client certificate", server: mutualTLS, opts: TLSOptions{CAFile: caFile, CertFile: clientCert, KeyFile: clientKey}},
+		{name: "missing client certificate", server: mutualTLS, opts: TLSOptions{CAFile: caFile}, wantErr: ErrTLS},
+		{name: "server below minimum version", server: tls12Only, opts: TLSOptions{CAFile: caFile, MinVersion: "1.3"}, wantErr: ErrTLS},
+		{name: "server at minimum version", server: tls12Only, opts: TLSOptions{CAFile: caFile, MinVersion: "TLS1.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := sendOverTLS(t, &fakeSMTP{tlsConfig: tt.server}, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(messages) != 0 {
+					t.Fatalf("server accepted %d messages, want none", len(messages))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("server accepted %d messages, want 1", len(messages))
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigInvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clientCert, _, _ := newClientCertificate(t, dir)
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{name: "unknown version", opts: TLSOptions{MinVersion: "1.4"}},
+		{name: "missing CA file", opts: TLSOptions{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "CA file without certificates", opts: TLSOptions{CAFile: notPEM}},
+		{name: "certificate without key", opts: TLSOptions{CertFile: clientCert}},
+		{name: "short fingerprint", opts: TLSOptions{Fingerprints: []string{"AB:CD"}}},
+		{name: "fingerprint not in hex", opts: TLSOptions{Fingerprints: []string{strings.Repeat("zz", sha256.Size)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTLSConfig(tt.opts); err == nil {
+				t.Errorf("NewTLSConfig(%+v) succeeded", tt.opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDialTimeout bounds how long connecting to the SMTP server may take.
+	DefaultDialTimeout = 30 * time.Second
+	// DefaultIOTimeout bounds how long any single read or write on the SMTP connection may take.
+	DefaultIOTimeout = 60 * time.Second
+)
+
+// deadlineConn refreshes an I/O deadline before every read and write, and
+// aborts all pending I/O once its context is done.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+	stop    func() bool
+
+	mu       sync.Mutex
+	aborted  bool
+	timedOut bool
+}
+
+// newDeadlineConn wraps conn so that every read and write is bounded by timeout
+// and cancelling ctx interrupts any blocked I/O.
+// Parameters:
+// - ctx: The context whose cancellation aborts the connection.
+// - conn: The connection to wrap.
+// - timeout: The per-operation I/O timeout; zero disables it.
+func newDeadlineConn(ctx context.Context, conn net.Conn, timeout time.Duration) *deadlineConn {
+	c := &deadlineConn{Conn: conn, timeout: timeout}
+	c.stop = context.AfterFunc(ctx, c.abort)
+	return c
+}
+
+// rebind ties the connection to a new context, for a pooled session that is
+// reused by a later send. Connections that were aborted are never reused.
+// Parameters:
+// - ctx: The context whose cancellation aborts the connection from now on.
+func (c *deadlineConn) rebind(ctx context.Context) {
+	c.stop()
+	c.mu.Lock()
+	c.timedOut = false
+	c.mu.Unlock()
+	c.stop = context.AfterFunc(ctx, c.abort)
+}
+
+// abort makes all current and future I/O on the connection fail immediately.
+func (c *deadlineConn) abort() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aborted = true
+	c.Conn.SetDeadline(time.Unix(1, 0))
+}
+
+// refreshDeadline extends the deadline for the next operation unless the connection was aborted.
+func (c *deadlineConn) refreshDeadline() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aborted {
+		return net.ErrClosed
+	}
+	if c.timeout > 0 {
+		return c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return nil
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if err := c.refreshDeadline(); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(p)
+	c.recordTimeout(err)
+	return n, err
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if err := c.refreshDeadline(); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(p)
+	c.recordTimeout(err)
+	return n, err
+}
+
+// recordTimeout remembers whether an operation failed because the I/O timeout expired.
+func (c *deadlineConn) recordTimeout(err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		c.mu.Lock()
+		c.timedOut = !c.aborted
+		c.mu.Unlock()
+	}
+}
+
+// hasTimedOut reports whether a read or write exceeded the I/O timeout.
+func (c *deadlineConn) hasTimedOut() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timedOut
+}
+
+func (c *deadlineConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// wrapSendError turns errors caused by the context or by timeouts into errors
+// callers can recognise with errors.Is: context.Canceled,
+// context.DeadlineExceeded or ErrTimeout.
+// Parameters:
+// - ctx: The context the send was running under.
+// - conn: The SMTP connection, or nil if it was never established.
+// - err: The error returned by the send.
+func wrapSendError(ctx context.Context, conn *deadlineConn, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("email sending aborted: %w (%v)", ctxErr, err)
+	}
+	if conn != nil && conn.hasTimedOut() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
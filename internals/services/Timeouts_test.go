@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingSMTP returns a fakeSMTP that stops responding once it receives
+// MAIL, until the test ends.
+func stallingSMTP(t *testing.T) *fakeSMTP {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	return &fakeSMTP{replies: func(command string) string {
+		if strings.HasPrefix(command, "MAIL") {
+			<-release
+		}
+		return ""
+	}}
+}
+
+func TestSendTimesOutWhenServerStopsResponding(t *testing.T) {
+	server := stallingSMTP(t)
+	host, port := server.start(t)
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone),
+		WithTimeouts(time.Second, 100*time.Millisecond),
+	)
+
+	start := time.Now()
+	err := service.Send(context.Background(), &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "Timeout test",
+		TextBody:   "Hello",
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Send() error = %v, want %v", err, ErrTimeout)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() error = %v, must not be a context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send() took %s to time out", elapsed)
+	}
+}
+
+func TestSendAbortsWhenContextIsCancelled(t *testing.T) {
+	server := stallingSMTP(t)
+	host, port := server.start(t)
+	service := NewDhanuEmailService(host, port, "sender@example.com", "secret",
+		WithSecurity(SecurityNone),
+		WithTimeouts(time.Minute, time.Minute),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	err := service.Send(ctx, &Message{
+		Recipients: Recipients{To: []string{"rcpt@example.com"}},
+		Subject:    "Cancel test",
+		TextBody:   "Hello",
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send() error = %v, want %v", err, context.Canceled)
+	}
+	if errors.Is(err, ErrTimeout) {
+		t.Errorf("Send() error = %v, must not be an I/O timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send() took %s to notice the cancellation", elapsed)
+	}
+	if messages := server.Messages(); len(messages) != 0 {
+		t.Errorf("server received %d messages, want none", len(messages))
+	}
+}
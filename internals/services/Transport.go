@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"io"
+)
+
+// Names of the built-in transports, as used in the configuration.
+const (
+	TransportSMTP     = "smtp"
+	TransportSendmail = "sendmail"
+	TransportFile     = "file"
+	TransportMaildir  = "maildir"
+	TransportStdout   = "stdout"
+	TransportSendGrid = "sendgrid"
+	TransportMailgun  = "mailgun"
+	TransportSES      = "ses"
+	TransportPostmark = "postmark"
+)
+
+// Transport delivers a built message. The SMTP transport is used by default;
+// WithTransport selects another one.
+type Transport interface {
+	// Deliver sends one message.
+	// Parameters:
+	// - ctx: Cancels the delivery when done.
+	// - from: The envelope sender.
+	// - to: The envelope recipients, including any Bcc addresses.
+	// - message: The complete message with CRLF line endings.
+	Deliver(ctx context.Context, from string, to []string, message string) error
+}
+
+// StreamTransport is a Transport that can deliver a message while it is being
+// built, so large attachments are never held in memory. Send uses it whenever
+// the message needs no signing, encryption or DKIM signature.
+type StreamTransport interface {
+	Transport
+
+	// DeliverStream sends one message written by write. When write fails,
+	// the partial message must not be delivered.
+	// Parameters:
+	// - ctx: Cancels the delivery when done.
+	// - from: The envelope sender.
+	// - to: The envelope recipients, including any Bcc addresses.
+	// - write: Writes the complete message with CRLF line endings; it may be called once per attempt.
+	DeliverStream(ctx context.Context, from string, to []string, write func(io.Writer) error) error
+}
+
+// errorWriter remembers the first error of the underlying writer, so a failed
+// delivery can be told apart from a message that failed to build.
+type errorWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errorWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// lfWriter converts CRLF line endings to the LF endings local mailers and
+// terminals expect, as the message streams through it.
+type lfWriter struct {
+	w  io.Writer
+	cr bool // A CR ended the previous write; it is dropped if an LF follows.
+}
+
+func (l *lfWriter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p)+1)
+	for _, c := range p {
+		if l.cr && c != '\n' {
+			out = append(out, '\r')
+		}
+		l.cr = c == '\r'
+		if !l.cr {
+			out = append(out, c)
+		}
+	}
+	if _, err := l.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a CR held back at the end of the last write.
+func (l *lfWriter) Flush() error {
+	if !l.cr {
+		return nil
+	}
+	l.cr = false
+	_, err := l.w.Write([]byte{'\r'})
+	return err
+}
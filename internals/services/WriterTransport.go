@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+)
+
+// WriterTransport prints messages to a writer such as os.Stdout, which is
+// handy for debugging and for piping into other tools.
+type WriterTransport struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterTransport creates a transport that writes every message to w.
+// Parameters:
+// - w: The destination, e.g. os.Stdout.
+func NewWriterTransport(w io.Writer) *WriterTransport {
+	return &WriterTransport{w: w}
+}
+
+// Deliver writes the message with LF line endings, followed by a blank line
+// separating it from the next one.
+// Parameters:
+// - ctx: Checked before writing.
+// - from: The envelope sender (unused).
+// - to: The envelope recipients (unused).
+// - message: The complete message with CRLF line endings.
+func (t *WriterTransport) Deliver(ctx context.Context, from string, to []string, message string) error {
+	return t.DeliverStream(ctx, from, to, func(w io.Writer) error {
+		_, err := io.WriteString(w, message)
+		return err
+	})
+}
+
+// DeliverStream writes the message like Deliver as it is built.
+// Parameters:
+// - ctx: Checked before writing.
+// - from: The envelope sender (unused).
+// - to: The envelope recipients (unused).
+// - write: Writes the complete message.
+func (t *WriterTransport) DeliverStream(ctx context.Context, from string, to []string, write func(io.Writer) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	buffered := bufio.NewWriter(t.w)
+	converter := &lfWriter{w: buffered}
+	if err := write(converter); err != nil {
+		return err
+	}
+	if err := converter.Flush(); err != nil {
+		return err
+	}
+	buffered.WriteString("\n")
+	return buffered.Flush()
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriterTransport(t *testing.T) {
+	var out bytes.Buffer
+	transport := NewWriterTransport(&out)
+	for i := 0; i < 2; i++ {
+		if err := transport.Deliver(context.Background(), "sender@example.com", []string{"rcpt@example.com"}, testTransportMessage); err != nil {
+			t.Fatalf("Deliver() error = %v", err)
+		}
+	}
+	message := strings.ReplaceAll(testTransportMessage, "\r\n", "\n")
+	if want := message + "\n" + message + "\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out.Reset()
+	if err := transport.Deliver(ctx, "sender@example.com", []string{"rcpt@example.com"}, testTransportMessage); !errors.Is(err, context.Canceled) {
+		t.Errorf("Deliver() error = %v, want %v", err, context.Canceled)
+	}
+	if out.Len() != 0 {
+		t.Errorf("cancelled delivery wrote %q", out.String())
+	}
+}
+
+func TestWriterTransportSplitsCRLF(t *testing.T) {
+	// A CRLF split across writes still becomes one LF; a lone CR is kept.
+	var out bytes.Buffer
+	err := NewWriterTransport(&out).DeliverStream(context.Background(), "sender@example.com", nil, func(w io.Writer) error {
+		for _, chunk := range []string{"Subject: a\r", "\n\r\nbody\r", "x\r"} {
+			if _, err := io.WriteString(w, chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DeliverStream() error = %v", err)
+	}
+	if want := "Subject: a\n\nbody\rx\r\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Elements whose content is never rendered as text.
+	hiddenElementsRe = regexp.MustCompile(`(?is)<(script|style|head|title)\b.*?</(script|style|head|title)\s*>`)
+	commentRe        = regexp.MustCompile(`(?s)<!--.*?-->`)
+	whitespaceRe     = regexp.MustCompile(`\s+`)
+	lineBreakRe      = regexp.MustCompile(`(?i)<br\s*/?>`)
+	paragraphEndRe   = regexp.MustCompile(`(?i)</?(p|h[1-6]|table|blockquote|ul|ol)\b[^>]*>`)
+	blockEndRe       = regexp.MustCompile(`(?i)</(div|tr)\s*>|<hr\b[^>]*>`)
+	listItemRe       = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	cellEndRe        = regexp.MustCompile(`(?i)</t[dh]\s*>`)
+	linkRe           = regexp.MustCompile(`(?is)<a\b[^>]*?href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a\s*>`)
+	tagRe            = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesRe     = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToText renders an HTML document as readable plain text for the
+// text/plain alternative of an HTML email.
+// Parameters:
+// - htmlBody: The HTML content to convert.
+// Returns the plain-text rendering.
+func HTMLToText(htmlBody string) string {
+	text := hiddenElementsRe.ReplaceAllString(htmlBody, "")
+	text = commentRe.ReplaceAllString(text, "")
+
+	// Source whitespace is insignificant in HTML; line breaks come from the markup.
+	text = whitespaceRe.ReplaceAllString(text, " ")
+
+	// Keep link targets visible, since the reader cannot click them.
+	text = linkRe.ReplaceAllStringFunc(text, func(link string) string {
+		match := linkRe.FindStringSubmatch(link)
+		href, label := match[1], strings.TrimSpace(tagRe.ReplaceAllString(match[2], ""))
+		if href == "" || strings.HasPrefix(href, "#") || href == label || strings.HasPrefix(href, "cid:") {
+			return label
+		}
+		return label + " (" + href + ")"
+	})
+
+	text = lineBreakRe.ReplaceAllString(text, "\n")
+	text = paragraphEndRe.ReplaceAllString(text, "\n\n")
+	text = blockEndRe.ReplaceAllString(text, "\n")
+	text = listItemRe.ReplaceAllString(text, "\n- ")
+	text = cellEndRe.ReplaceAllString(text, "\t")
+	text = tagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	// Tidy up the whitespace left behind by the removed markup.
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = strings.Join(lines, "\n")
+	text = blankLinesRe.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text) + "\n"
+}
@@ -2,10 +2,14 @@ package utils
 
 import "regexp"
 
+// emailRe matches addresses whose local part and domain may contain Unicode
+// letters and digits (RFC 6531), e.g. "用户@例子.广告" or "josé@correo.es".
+// Top-level domains are letters, or an A-label such as "xn--p1ai".
+var emailRe = regexp.MustCompile(`^[\p{L}\p{M}\p{N}._%+-]+@([\p{L}\p{M}\p{N}-]+\.)+(\p{L}[\p{L}\p{M}]+|xn--[a-zA-Z0-9-]+)$`)
+
 // Function to validate email format
 func IsValidEmail(email string) bool {
-	// Simple regex for validating an email format
-	// This regex may not cover all edge cases, but it works for most common email formats.
-	re := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	return re.MatchString(email)
+	// This regex may not cover all edge cases, but it accepts the common
+	// formats, including internationalized addresses.
+	return emailRe.MatchString(email)
 }
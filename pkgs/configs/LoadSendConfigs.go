@@ -11,11 +11,74 @@ import (
 
 type Config struct {
 	SMTP struct {
-		Host        string `mapstructure:"host"`
-		Port        int    `mapstructure:"port"`
-		FromEmail   string `mapstructure:"from_email"`  // Updated from Username to FromEmail
-		Credentials string `mapstructure:"credentials"` // Updated from Password to Credentials
+		Host            string `mapstructure:"host"`
+		Port            int    `mapstructure:"port"`
+		FromEmail       string `mapstructure:"from_email"`        // Updated from Username to FromEmail
+		FromName        string `mapstructure:"from_name"`         // Display name shown in the From header
+		Credentials     string `mapstructure:"credentials"`       // Updated from Password to Credentials
+		Security        string `mapstructure:"security"`          // auto, tls, starttls, opportunistic or none
+		Auth            string `mapstructure:"auth"`              // auto, none, plain, login, cram-md5 or xoauth2
+		DialTimeout     int    `mapstructure:"dial_timeout"`      // Seconds allowed to connect to the SMTP server
+		IOTimeout       int    `mapstructure:"io_timeout"`        // Seconds allowed for each read or write on the connection
+		Retries         int    `mapstructure:"retries"`           // Additional attempts after a transient failure
+		RetryDelay      int    `mapstructure:"retry_delay"`       // Maximum seconds to wait between attempts
+		MessageIDDomain string `mapstructure:"message_id_domain"` // Domain of generated Message-IDs; defaults to the sender's domain
+		HeloName        string `mapstructure:"helo_name"`         // Name sent with EHLO/HELO; defaults to the machine's FQDN
+		LocalAddress    string `mapstructure:"local_address"`     // Local IP address or interface to connect from
+		IPFamily        string `mapstructure:"ip_family"`         // auto, ipv4 or ipv6
+		TLS             struct {
+			CAFile             string   `mapstructure:"ca_file"`              // PEM CA bundle trusted instead of the system roots
+			CertFile           string   `mapstructure:"cert_file"`            // PEM client certificate for mutual TLS
+			KeyFile            string   `mapstructure:"key_file"`             // PEM private key of the client certificate
+			ServerName         string   `mapstructure:"server_name"`          // Name the server certificate must match; defaults to host
+			MinVersion         string   `mapstructure:"min_version"`          // Lowest accepted TLS version: 1.0, 1.1, 1.2 or 1.3
+			Fingerprints       []string `mapstructure:"fingerprints"`         // SHA-256 fingerprints the server certificate must match
+			InsecureSkipVerify bool     `mapstructure:"insecure_skip_verify"` // Accept any server certificate; for lab use only
+		} `mapstructure:"tls"`
 	} `mapstructure:"smtp"`
+	Transport struct {
+		Type         string `mapstructure:"type"`          // smtp, sendmail, file, maildir, stdout, sendgrid, mailgun, ses or postmark
+		SendmailPath string `mapstructure:"sendmail_path"` // sendmail binary; defaults to /usr/sbin/sendmail
+		Dir          string `mapstructure:"dir"`           // Output directory of the file and maildir transports
+	} `mapstructure:"transport"`
+	DKIM struct {
+		Domain     string   `mapstructure:"domain"`      // Signing domain (d=); defaults to the domain of from_email
+		Selector   string   `mapstructure:"selector"`    // Selector (s=) the public key is published under
+		PrivateKey string   `mapstructure:"private_key"` // Path to the PEM private key; DKIM is disabled when empty
+		Headers    []string `mapstructure:"headers"`     // Header fields to sign; empty uses the default list
+	} `mapstructure:"dkim"`
+	Protection string `mapstructure:"protection"` // pgp or smime: how send --sign and --encrypt protect email
+	PGP        struct {
+		Keyring    string `mapstructure:"keyring"`     // Directory of recipients' public keys; defaults to "keyring" next to the config file
+		SigningKey string `mapstructure:"signing_key"` // Path to the private key used by send --sign
+		Passphrase string `mapstructure:"passphrase"`  // Passphrase of the signing key, if it is protected
+	} `mapstructure:"pgp"`
+	SMIME struct {
+		Certificate  string `mapstructure:"certificate"`  // PEM signing certificate, optionally followed by its chain
+		PrivateKey   string `mapstructure:"private_key"`  // PEM private key of the signing certificate
+		Certificates string `mapstructure:"certificates"` // Directory of recipients' certificates; defaults to "certs" next to the config file
+	} `mapstructure:"smime"`
+	SendGrid struct {
+		APIKey   string `mapstructure:"api_key"`  // API key with the Mail Send permission
+		Endpoint string `mapstructure:"endpoint"` // API base URL; defaults to https://api.sendgrid.com
+	} `mapstructure:"sendgrid"`
+	Mailgun struct {
+		APIKey   string `mapstructure:"api_key"`  // API key or domain sending key
+		Domain   string `mapstructure:"domain"`   // Sending domain configured in Mailgun
+		Endpoint string `mapstructure:"endpoint"` // API base URL; https://api.eu.mailgun.net for EU domains
+	} `mapstructure:"mailgun"`
+	SES struct {
+		Region          string `mapstructure:"region"`            // AWS region; defaults to $AWS_REGION
+		AccessKeyID     string `mapstructure:"access_key_id"`     // Defaults to $AWS_ACCESS_KEY_ID
+		SecretAccessKey string `mapstructure:"secret_access_key"` // Defaults to $AWS_SECRET_ACCESS_KEY
+		SessionToken    string `mapstructure:"session_token"`     // Defaults to $AWS_SESSION_TOKEN
+		Endpoint        string `mapstructure:"endpoint"`          // API base URL; defaults to the region's endpoint
+	} `mapstructure:"ses"`
+	Postmark struct {
+		ServerToken   string `mapstructure:"server_token"`   // Server API token
+		MessageStream string `mapstructure:"message_stream"` // Message stream; defaults to the server's transactional stream
+		Endpoint      string `mapstructure:"endpoint"`       // API base URL; defaults to https://api.postmarkapp.com
+	} `mapstructure:"postmark"`
 	DefaultRecipient string `mapstructure:"default_recipient"`
 	SetupCompleted   bool   `mapstructure:"setup_completed"` // New field to track if setup is completed
 }
@@ -50,7 +113,17 @@ func LoadConfig() (Config, string, error) {
 		config.SMTP.Host = ""
 		config.SMTP.Port = 0
 		config.SMTP.FromEmail = ""
+		config.SMTP.FromName = ""
 		config.SMTP.Credentials = ""
+		config.SMTP.Security = "auto"
+		config.SMTP.Auth = "auto"
+		config.SMTP.DialTimeout = 30
+		config.SMTP.IOTimeout = 60
+		config.SMTP.Retries = 3
+		config.SMTP.RetryDelay = 60
+		config.SMTP.IPFamily = "auto"
+		config.Transport.Type = "smtp"
+		config.Protection = "pgp"
 		config.DefaultRecipient = ""
 		config.SetupCompleted = false // Mark setup as incomplete
 
@@ -13,6 +13,51 @@ func SaveConfig(config Config, configPath string) error {
 	viper.Set("smtp.port", config.SMTP.Port)
 	viper.Set("smtp.from_email", config.SMTP.FromEmail)    // Updated field name
 	viper.Set("smtp.credentials", config.SMTP.Credentials) // Updated field name
+	viper.Set("smtp.from_name", config.SMTP.FromName)
+	viper.Set("smtp.security", config.SMTP.Security)
+	viper.Set("smtp.auth", config.SMTP.Auth)
+	viper.Set("smtp.dial_timeout", config.SMTP.DialTimeout)
+	viper.Set("smtp.io_timeout", config.SMTP.IOTimeout)
+	viper.Set("smtp.retries", config.SMTP.Retries)
+	viper.Set("smtp.retry_delay", config.SMTP.RetryDelay)
+	viper.Set("smtp.message_id_domain", config.SMTP.MessageIDDomain)
+	viper.Set("smtp.helo_name", config.SMTP.HeloName)
+	viper.Set("smtp.local_address", config.SMTP.LocalAddress)
+	viper.Set("smtp.ip_family", config.SMTP.IPFamily)
+	viper.Set("smtp.tls.ca_file", config.SMTP.TLS.CAFile)
+	viper.Set("smtp.tls.cert_file", config.SMTP.TLS.CertFile)
+	viper.Set("smtp.tls.key_file", config.SMTP.TLS.KeyFile)
+	viper.Set("smtp.tls.server_name", config.SMTP.TLS.ServerName)
+	viper.Set("smtp.tls.min_version", config.SMTP.TLS.MinVersion)
+	viper.Set("smtp.tls.fingerprints", config.SMTP.TLS.Fingerprints)
+	viper.Set("smtp.tls.insecure_skip_verify", config.SMTP.TLS.InsecureSkipVerify)
+	viper.Set("transport.type", config.Transport.Type)
+	viper.Set("transport.sendmail_path", config.Transport.SendmailPath)
+	viper.Set("transport.dir", config.Transport.Dir)
+	viper.Set("dkim.domain", config.DKIM.Domain)
+	viper.Set("dkim.selector", config.DKIM.Selector)
+	viper.Set("dkim.private_key", config.DKIM.PrivateKey)
+	viper.Set("dkim.headers", config.DKIM.Headers)
+	viper.Set("protection", config.Protection)
+	viper.Set("pgp.keyring", config.PGP.Keyring)
+	viper.Set("pgp.signing_key", config.PGP.SigningKey)
+	viper.Set("pgp.passphrase", config.PGP.Passphrase)
+	viper.Set("smime.certificate", config.SMIME.Certificate)
+	viper.Set("smime.private_key", config.SMIME.PrivateKey)
+	viper.Set("smime.certificates", config.SMIME.Certificates)
+	viper.Set("sendgrid.api_key", config.SendGrid.APIKey)
+	viper.Set("sendgrid.endpoint", config.SendGrid.Endpoint)
+	viper.Set("mailgun.api_key", config.Mailgun.APIKey)
+	viper.Set("mailgun.domain", config.Mailgun.Domain)
+	viper.Set("mailgun.endpoint", config.Mailgun.Endpoint)
+	viper.Set("ses.region", config.SES.Region)
+	viper.Set("ses.access_key_id", config.SES.AccessKeyID)
+	viper.Set("ses.secret_access_key", config.SES.SecretAccessKey)
+	viper.Set("ses.session_token", config.SES.SessionToken)
+	viper.Set("ses.endpoint", config.SES.Endpoint)
+	viper.Set("postmark.server_token", config.Postmark.ServerToken)
+	viper.Set("postmark.message_stream", config.Postmark.MessageStream)
+	viper.Set("postmark.endpoint", config.Postmark.Endpoint)
 	viper.Set("default_recipient", config.DefaultRecipient)
 	viper.Set("setup_completed", config.SetupCompleted) // Track setup completion
 